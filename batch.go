@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// group items from `in` into slices of up to `maxSize` items
+//
+// a batch is emitted when it's full or when its oldest item has waited for `maxWait`
+// (`maxWait` <= 0 disables the time limit), the partial batch is flushed when `in` is closed
+func Batch[T any](ctx context.Context, in <-chan T, maxSize int, maxWait time.Duration, opts ...Option) <-chan []T {
+	maxSize = max(maxSize, 1)
+
+	st := newStage(ctx, "Batch", opts)
+	input := newInput(st, in)
+	out := newOutput[[]T](st)
+
+	spawn(ctx, st.origin(), func() {
+		defer st.end()
+		defer out.close()
+
+		var batch []T
+		var wm time.Time // the oldest watermark of batch items, so they are not late downstream
+		var timer Timer
+		var expired <-chan time.Time // triggered when the oldest item has waited `maxWait`
+
+		flush := func() bool {
+			if timer != nil {
+				timer.Stop()
+				timer, expired = nil, nil
+			}
+
+			r := batch
+			batch = nil
+			return out.write(ctx, item[[]T]{val: r, wm: wm})
+		}
+
+		for {
+			it, ok, timeout := input.readUntil(ctx, expired)
+			if timeout {
+				if !flush() {
+					return
+				}
+				continue
+			}
+
+			if !ok {
+				if ctx.Err() == nil && len(batch) > 0 {
+					// input was closed, flush the rest
+					_ = flush()
+				}
+				return
+			}
+
+			if batch == nil {
+				batch = make([]T, 0, maxSize)
+
+				if maxWait > 0 {
+					timer = st.clock.NewTimer(maxWait)
+					expired = timer.C()
+				}
+			}
+
+			if len(batch) == 0 || it.wm.Before(wm) {
+				wm = it.wm
+			}
+			batch = append(batch, it.val)
+
+			if len(batch) == maxSize {
+				if !flush() {
+					return
+				}
+			}
+		}
+	})
+
+	return out.ch
+}
@@ -0,0 +1,113 @@
+package pipeline_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 10)
+	batches := pl.Batch(ctx, seq, 4, time.Hour)
+
+	pipelinetest.WithTimeout(t, "read batches", func() {
+		var res [][]int
+		for b := range batches {
+			res = append(res, b)
+		}
+
+		// partial batch is flushed on close
+		assert.Equal(t, [][]int{{0, 1, 2, 3}, {4, 5, 6, 7}, {8, 9}}, res)
+	})
+}
+
+func TestBatch_MaxWait(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan int)
+	batches := pl.Batch(ctx, input, 100, 10*time.Millisecond)
+
+	pipelinetest.WithTimeout(t, "write items", func() {
+		input <- 1
+		input <- 2
+	})
+
+	start := time.Now()
+	b := pipelinetest.CheckRead(t, batches)
+	assert.Equal(t, []int{1, 2}, b)
+	assert.GreaterOrEqual(t, time.Since(start), 5*time.Millisecond)
+
+	// timer starts with the first item of a new batch
+	time.Sleep(20 * time.Millisecond)
+	pipelinetest.CheckPending(t, batches)
+
+	pipelinetest.WithTimeout(t, "write items", func() {
+		input <- 3
+	})
+
+	b = pipelinetest.CheckRead(t, batches)
+	assert.Equal(t, []int{3}, b)
+
+	close(input)
+	pipelinetest.WithTimeout(t, "wait closed", func() {
+		_, ok := <-batches
+		assert.False(t, ok)
+	})
+}
+
+func TestBatch_EmptyInput(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan int)
+	close(input)
+
+	batches := pl.Batch(ctx, input, 10, 0)
+
+	pipelinetest.WithTimeout(t, "wait closed", func() {
+		_, ok := <-batches
+		assert.False(t, ok) // no empty batches
+	})
+}
+
+func TestBatch_DontStuck(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	inf := pl.Generate(ctx, func(w pl.Writer[int]) {
+		k := 0
+		for w.Write(k) {
+			k += 1
+		}
+	})
+
+	// never read
+	_ = pl.Batch(ctx, inf, 10, time.Millisecond)
+
+	pipelinetest.CheckShutdown(t, cancel)
+}
+
+func TestBatch_CancelDropsPartial(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	input := make(chan int)
+	batches := pl.Batch(ctx, input, 10, time.Hour)
+
+	pipelinetest.WithTimeout(t, "write items", func() {
+		input <- 1
+	})
+
+	pipelinetest.CheckShutdown(t, cancel)
+
+	pipelinetest.WithTimeout(t, "wait closed", func() {
+		_, ok := <-batches
+		assert.False(t, ok)
+	})
+}
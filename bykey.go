@@ -0,0 +1,330 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// same as `Process`, but items with the same key are processed one at a time in the input order,
+// items with different keys are processed in parallel by `threads` workers
+func ProcessByKey[T any, K comparable](ctx context.Context, threads int, in <-chan T, key func(T) K, cb func(T), opts ...Option) Signal {
+	st := newStage(ctx, "ProcessByKey", opts)
+	input := newInput(st, in)
+
+	handle := processItem(ctx, st, false, OneshotMut[error]{}, nil, func(v T) error {
+		cb(v)
+		return nil
+	})
+	workers := startKeyWorkers(ctx, st, threads, input, key, handle)
+
+	return signalAfterAll(ctx, st, workers, nil)
+}
+
+// fallible version of `ProcessByKey`, other keys are still processed after error,
+// but the failed key is stalled: its later items are dropped and reported as `ErrKeyFailed`;
+// errors of the first `threads` failed keys are sent to the error channel
+func ProcessByKeyErr[T any, K comparable](ctx context.Context, threads int, in <-chan T, key func(T) K, cb func(T) error, opts ...Option) (Signal, Oneshot[error]) {
+	st := newStage(ctx, "ProcessByKeyErr", opts)
+	input := newInput(st, in)
+	cherr := NewOneshotGroup[error](max(threads, 1)) // each worker can send one error
+	setupDeadLetters[T](st)
+
+	hasError := atomic.Bool{}
+	handle := processItem(ctx, st, true, cherr, &hasError, cb)
+	workers := startKeyWorkers(ctx, st, threads, input, key, handle)
+
+	finished := signalAfterAll(ctx, st, workers, &hasError)
+
+	return finished, cherr.Chan()
+}
+
+// same as `Transform`, but items with the same key are processed one at a time
+// and their results are emitted in the input order,
+// items with different keys are processed in parallel by `threads` workers
+func TransformByKey[T any, U any, K comparable](ctx context.Context, threads int, in <-chan T, key func(T) K, cb func(T) U, opts ...Option) <-chan U {
+	out, _ := transformByKey(ctx, "TransformByKey", threads, in, key, false, opts, func(v T) (U, bool, error) {
+		return cb(v), true, nil
+	})
+	return out
+}
+
+// fallible version of `TransformByKey`, other keys are still processed after error,
+// but the failed key is stalled: its later items are dropped and reported as `ErrKeyFailed`;
+// errors of the first `threads` failed keys are sent to the error channel
+func TransformByKeyErr[T any, U any, K comparable](ctx context.Context, threads int, in <-chan T, key func(T) K, cb func(T) (U, error), opts ...Option) (<-chan U, Oneshot[error]) {
+	return transformByKey(ctx, "TransformByKeyErr", threads, in, key, true, opts, func(v T) (U, bool, error) {
+		r, err := cb(v)
+		return r, true, err
+	})
+}
+
+// same as `transform`, but items are passed to workers by `startKeyWorkers`
+func transformByKey[T any, U any, K comparable](ctx context.Context, api string, threads int, in <-chan T, key func(T) K, fallible bool, opts []Option, cb func(T) (U, bool, error)) (<-chan U, Oneshot[error]) {
+	st := newStage(ctx, api, opts)
+	input := newInput(st, in)
+	out := newOutput[U](st)
+
+	var cherr OneshotMut[error]
+	if fallible {
+		cherr = NewOneshotGroup[error](max(threads, 1)) // each worker can send one error
+		setupDeadLetters[T](st)
+	}
+
+	hasError := atomic.Bool{}
+	handle := transformItem(ctx, st, fallible, cherr, &hasError, out, cb)
+	workers := startKeyWorkers(ctx, st, threads, input, key, handle)
+
+	closeAfterAll(ctx, st, workers, &hasError, out)
+
+	return out.ch, cherr.Chan()
+}
+
+// items of a failed key that are dropped by `ProcessByKeyErr` or `TransformByKeyErr`
+// are reported to `Errors` with this error (one report per stalled key)
+var ErrKeyFailed = errors.New("key has failed")
+
+// max number of items per worker that are read, but not processed yet;
+// input is not read when the limit is reached, e.g. when a single key is too busy
+const keyBacklogPerWorker = 64
+
+// items of a single key that are being processed
+type keyQueue[T any, K comparable] struct {
+	key     K
+	items   []item[T]
+	failed  bool // key is stalled after error, its items are dropped
+	dropped int  // number of items dropped since the key has failed
+}
+
+type keyScheduler[T any, K comparable] struct {
+	st     *stage
+	mu     sync.Mutex
+	queues map[K]*keyQueue[T, K] // queue is evicted as soon as it becomes empty, failed queue is kept
+
+	// failed queues in the order of failure, the oldest one is evicted
+	// when there are more than `maxFailed`, so memory stays bounded
+	failed    []*keyQueue[T, K]
+	maxFailed int
+
+	ready chan *keyQueue[T, K] // new queues that wait for a worker
+	slots chan struct{}        // items that are read, but not processed yet
+}
+
+// push item to the queue of its key, returns new queue that must be passed to a worker
+func (s *keyScheduler[T, K]) push(key K, it item[T]) *keyQueue[T, K] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if q, ok := s.queues[key]; ok {
+		if q.failed {
+			q.dropped += 1
+			s.release(it)
+		} else {
+			q.items = append(q.items, it) // key is busy, its worker will take the item
+		}
+		return nil
+	}
+
+	q := &keyQueue[T, K]{key: key, items: []item[T]{it}}
+	s.queues[key] = q
+	return q
+}
+
+// take the next item of the queue, evict the queue if it's empty
+func (s *keyScheduler[T, K]) pop(q *keyQueue[T, K]) (item[T], bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(q.items) == 0 {
+		delete(s.queues, q.key)
+		return item[T]{}, false
+	}
+
+	it := q.items[0]
+	q.items[0] = item[T]{}
+	q.items = q.items[1:]
+	return it, true
+}
+
+// stall the key after error, its pending items are dropped, so the worker can take other queues;
+// returns the oldest failed queue if it's evicted, its later items are processed again
+func (s *keyScheduler[T, K]) fail(q *keyQueue[T, K]) *keyQueue[T, K] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q.failed = true
+	q.dropped += len(q.items)
+	for _, it := range q.items {
+		s.release(it)
+	}
+	q.items = nil
+
+	s.failed = append(s.failed, q)
+	if len(s.failed) <= s.maxFailed {
+		return nil
+	}
+
+	old := s.failed[0]
+	s.failed[0] = nil
+	s.failed = s.failed[1:]
+	delete(s.queues, old.key)
+	return old
+}
+
+// item is processed or dropped
+func (s *keyScheduler[T, K]) release(it item[T]) {
+	s.st.wm.done(it.seq)
+	<-s.slots
+}
+
+// report items dropped by failed queues
+func (s *keyScheduler[T, K]) reportDropped(ctx context.Context, queues ...*keyQueue[T, K]) {
+	for _, q := range queues {
+		s.mu.Lock()
+		n := q.dropped
+		q.dropped = 0
+		s.mu.Unlock()
+
+		if n > 0 {
+			s.st.reportError(ctx, fmt.Errorf("%w: %d items of key %v are dropped", ErrKeyFailed, n, q.key))
+		}
+	}
+}
+
+// spawn `threads` workers that `handle` items of `in`, items with the same key
+// are passed to one worker at a time in the input order
+//
+// worker takes the next queue when `handle` returns `false` unless stage is cancelled,
+// the failed key is stalled then; at most `threads * keyBacklogPerWorker` keys are stalled,
+// the oldest one is resumed when the limit is exceeded; number of workers can be changed by `Pool` and `Autoscale`
+// options, items are passed to `handle` according to `RateLimit` option
+func startKeyWorkers[T any, K comparable](ctx context.Context, st *stage, threads int, in *input[T], key func(T) K, handle func(item[T]) bool) *workerGroup {
+	if st.watermarked {
+		st.wm = newWatermarkTracker()
+	}
+
+	limit := max(threads, 1) * keyBacklogPerWorker
+	if auto := st.cfg.autoscale; auto != nil {
+		limit = max(limit, auto.max*keyBacklogPerWorker)
+	}
+
+	s := &keyScheduler[T, K]{
+		st:        st,
+		queues:    make(map[K]*keyQueue[T, K]),
+		maxFailed: limit,
+		ready:     make(chan *keyQueue[T, K], limit), // each queue holds at least one slot, so it never blocks
+		slots:     make(chan struct{}, limit),
+	}
+
+	// dispatcher: put items to the queues of their keys
+	spawn(ctx, st.origin(), func() {
+		defer close(s.ready)
+
+		for {
+			if !Write(ctx, s.slots, struct{}{}) {
+				return
+			}
+
+			it, ok := in.read(ctx)
+			if !ok {
+				return
+			}
+
+			var k K
+			if err := st.safeCall(func() error { k = key(it.val); return nil }); err != nil {
+				// key function panicked, the item is skipped
+				st.fail()
+				reportItemPanic(ctx, it.val, err)
+				s.release(it)
+				continue
+			}
+
+			if q := s.push(k, it); q != nil {
+				s.ready <- q
+			}
+		}
+	})
+
+	rate := stageLimiter[T](st)
+
+	// handle items of the queue until it's empty, returns `false` if stage is cancelled
+	run := func(q *keyQueue[T, K]) bool {
+		for {
+			it, ok := s.pop(q)
+			if !ok {
+				return true
+			}
+
+			if rate != nil {
+				err := rate.wait(ctx, st, it.val)
+				if perr, isPanic := err.(*PanicError); isPanic {
+					// key function panicked, the item is skipped
+					st.fail()
+					reportItemPanic(ctx, it.val, perr)
+					s.release(it)
+					continue
+				}
+				if err != nil {
+					s.release(it)
+					return false
+				}
+			}
+
+			ok = handle(it)
+			s.release(it)
+			if !ok {
+				if ctx.Err() != nil {
+					return false
+				}
+				if old := s.fail(q); old != nil {
+					s.reportDropped(ctx, old)
+				}
+				return true
+			}
+		}
+	}
+
+	var group *workerGroup
+	group = newWorkerGroup(func() {
+		st.stats.workerStarted()
+		spawn(ctx, st.origin(), func() {
+			defer st.stats.workerStopped()
+
+			for {
+				retire, wake := group.retire()
+				if retire {
+					return
+				}
+
+				q, ok, woken := readJob(ctx, group, wake, s.ready)
+				if woken {
+					continue // group was downsized, check whether to retire
+				}
+
+				// worker owns the queue until it's empty
+				if !ok || !run(q) {
+					group.exit()
+					return
+				}
+			}
+		})
+	})
+
+	// queues of keys wait for a worker, the input itself is read by dispatcher
+	st.startGroup(ctx, group, threads, func() int { return len(s.ready) })
+
+	// report items of the keys that are still stalled when all workers are finished
+	spawn(ctx, st.origin(), func() {
+		group.wait()
+
+		s.mu.Lock()
+		failed := s.failed
+		s.mu.Unlock()
+
+		s.reportDropped(ctx, failed...)
+	})
+
+	return group
+}
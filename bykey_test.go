@@ -0,0 +1,321 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessByKey(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	var mu sync.Mutex
+	res := make(map[int][]int)
+	active := make([]atomic.Int32, 5)
+
+	finished := pl.ProcessByKey(ctx, 8, sequence(ctx, 0, 500), func(x int) int {
+		return x % 5
+	}, func(x int) {
+		key := x % 5
+		assert.Equal(t, int32(1), active[key].Add(1), "key is processed concurrently")
+		defer active[key].Add(-1)
+
+		mu.Lock()
+		defer mu.Unlock()
+		res[key] = append(res[key], x)
+	})
+
+	pipelinetest.CheckSignaled(t, finished)
+
+	for key := range 5 {
+		assert.Len(t, res[key], 100)
+		for k, v := range res[key] {
+			assert.Equal(t, key+5*k, v) // input order
+		}
+	}
+}
+
+func TestProcessByKey_Parallel(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan string)
+	started := make(chan string, 3)
+	resume := make(chan struct{})
+
+	finished := pl.ProcessByKey(ctx, 2, input, func(s string) string {
+		return s[:1]
+	}, func(s string) {
+		started <- s
+		<-resume
+	})
+
+	input <- "a1"
+	input <- "a2"
+	input <- "b1"
+
+	// different keys run in parallel, the same key waits
+	assert.ElementsMatch(t, []string{"a1", "b1"}, []string{pipelinetest.CheckRead(t, started), pipelinetest.CheckRead(t, started)})
+	pipelinetest.CheckPending(t, started)
+
+	close(resume)
+	assert.Equal(t, "a2", pipelinetest.CheckRead(t, started))
+
+	close(input)
+	pipelinetest.CheckSignaled(t, finished)
+}
+
+func TestProcessByKeyErr(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	finished, cherr := pl.ProcessByKeyErr(ctx, 2, sequence(ctx, 0, 10), func(x int) int {
+		return x % 2
+	}, func(x int) error {
+		if x == 5 {
+			return errTest
+		}
+		return nil
+	})
+
+	assert.ErrorIs(t, pipelinetest.CheckRead(t, cherr), errTest)
+	pipelinetest.CheckPending(t, finished)
+}
+
+func TestTransformByKey(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	res := pl.TransformByKey(ctx, 4, sequence(ctx, 0, 300), func(x int) int {
+		return x % 3
+	}, func(x int) int {
+		return x * 2
+	})
+
+	last := map[int]int{0: -1, 1: -1, 2: -1}
+	count := 0
+	for _, v := range pipelinetest.ReadAll(t, res) {
+		key := v / 2 % 3
+		assert.Less(t, last[key], v) // results of a key are in the input order
+		last[key] = v
+		count += 1
+	}
+	assert.Equal(t, 300, count)
+}
+
+func TestTransformByKeyErr(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	res, cherr := pl.TransformByKeyErr(ctx, 2, sequence(ctx, 0, 10), func(x int) int {
+		return x % 2
+	}, func(x int) (int, error) {
+		if x == 0 {
+			return 0, errTest
+		}
+		return x, nil
+	})
+
+	assert.ErrorIs(t, pipelinetest.CheckRead(t, cherr), errTest)
+
+	// the failed key is stalled, results of other keys are emitted
+	for range 5 {
+		assert.Equal(t, 1, pipelinetest.CheckRead(t, res)%2)
+	}
+	pipelinetest.CheckPending(t, res)
+}
+
+func TestTransformByKey_Cancel(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	res := pl.TransformByKey(ctx, 2, sequence(ctx, 0, 100), func(x int) int {
+		return x % 2
+	}, func(x int) int {
+		return x
+	})
+
+	pipelinetest.CheckRead(t, res)
+	pipelinetest.CheckShutdown(t, cancel)
+}
+
+func TestTransformByKeyErr_SingleWorker(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	res, cherr := pl.TransformByKeyErr(ctx, 1, sequence(ctx, 0, 10), func(x int) int {
+		return x % 2
+	}, func(x int) (int, error) {
+		if x == 0 {
+			return 0, errTest
+		}
+		return x, nil
+	})
+
+	assert.ErrorIs(t, pipelinetest.CheckRead(t, cherr), errTest)
+
+	// worker keeps running after error, later items of the failed key are dropped
+	for _, v := range []int{1, 3, 5, 7, 9} {
+		assert.Equal(t, v, pipelinetest.CheckRead(t, res))
+	}
+	pipelinetest.CheckPending(t, res)
+}
+
+func TestProcessByKeyErr_ManyKeys(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	var processed atomic.Int32
+	finished, cherr := pl.ProcessByKeyErr(ctx, 1, sequence(ctx, 0, 30), func(x int) int {
+		return x % 10
+	}, func(x int) error {
+		if x < 3 {
+			return errTest // more failed keys than workers
+		}
+		processed.Add(1)
+		return nil
+	})
+
+	assert.ErrorIs(t, pipelinetest.CheckRead(t, cherr), errTest)
+	pipelinetest.WithTimeout(t, "process other keys", func() {
+		for processed.Load() < 21 {
+			time.Sleep(time.Millisecond)
+		}
+	})
+	pipelinetest.CheckPending(t, finished)
+}
+
+func TestProcessByKeyErr_DroppedReported(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	var processed atomic.Int32
+	finished, cherr := pl.ProcessByKeyErr(ctx, 1, sequence(ctx, 0, 10), func(x int) int {
+		return x % 2
+	}, func(x int) error {
+		if x == 0 {
+			return errTest
+		}
+		processed.Add(1)
+		return nil
+	})
+
+	assert.ErrorIs(t, pipelinetest.CheckRead(t, cherr), errTest)
+	pipelinetest.WithTimeout(t, "report dropped items", func() {
+		for !errors.Is(pl.Errors(ctx), pl.ErrKeyFailed) {
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	// all later items of the failed key are dropped
+	assert.ErrorContains(t, pl.Errors(ctx), "4 items of key 0 are dropped")
+	assert.Equal(t, int32(5), processed.Load())
+	pipelinetest.CheckPending(t, finished)
+}
+
+func TestProcessByKeyErr_FailedKeysBounded(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	const keys = 1000 // much more failed keys than are stalled
+	var resumed atomic.Bool
+	_, cherr := pl.ProcessByKeyErr(ctx, 1, sequence(ctx, 0, 2*keys), func(x int) int {
+		return x % keys
+	}, func(x int) error {
+		if x < keys {
+			return errTest
+		}
+		if x == keys {
+			resumed.Store(true)
+		}
+		return nil
+	})
+
+	assert.ErrorIs(t, pipelinetest.CheckRead(t, cherr), errTest)
+
+	// the oldest failed keys are evicted, so their later items are processed again
+	pipelinetest.WithTimeout(t, "resume failed key", func() {
+		for !resumed.Load() {
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
+
+func TestProcessByKey_KeyPanic(t *testing.T) {
+	ctx, cancel, p := newPanicPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	var mu sync.Mutex
+	var res []int
+	finished := pl.ProcessByKey(ctx, 2, sequence(ctx, 0, 4), func(x int) int {
+		if x == 2 {
+			panic("bad key")
+		}
+		return x
+	}, func(x int) {
+		mu.Lock()
+		defer mu.Unlock()
+		res = append(res, x)
+	})
+
+	pipelinetest.CheckSignaled(t, finished)
+	assert.ElementsMatch(t, []int{0, 1, 3}, res)
+
+	errs := p.Get()
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, 2, errs[0].Item)
+	}
+}
+
+func TestProcessByKey_Pool(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan string)
+	started := make(chan string, 3)
+	resume := make(chan struct{})
+
+	pool := &pl.WorkerPool{}
+	finished := pl.ProcessByKey(ctx, 1, input, func(s string) string {
+		return s[:1]
+	}, func(s string) {
+		started <- s
+		<-resume
+	}, pl.Pool(pool))
+
+	pool.Resize(2)
+	assert.Equal(t, 2, pool.Running())
+
+	input <- "a1"
+	input <- "b1"
+
+	// pool adds a worker for another key
+	assert.ElementsMatch(t, []string{"a1", "b1"}, []string{pipelinetest.CheckRead(t, started), pipelinetest.CheckRead(t, started)})
+
+	close(resume)
+	close(input)
+	pipelinetest.CheckSignaled(t, finished)
+	assert.Equal(t, 0, pool.Running())
+}
+
+func TestTransformByKey_RateLimit(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	start := time.Now()
+	res := pl.TransformByKey(ctx, 4, sequence(ctx, 0, 6), func(x int) int {
+		return x % 3
+	}, func(x int) int {
+		return x
+	}, pl.RateLimit(100, 1))
+
+	assert.Len(t, pipelinetest.ReadAll(t, res), 6)
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+}
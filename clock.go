@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// source of time for all time-dependent functions (`Batch`, `Retry`, `Throttle`,
+// `Drain`, stats, tracing, etc.), see `WithClock`
+//
+// `pipelinetest.FakeClock` can be used to control time in tests
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// `time.Timer` of `Clock`
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// `time.Ticker` of `Clock`
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// clock of `time` package, it's used if context has no clock
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+// set clock for all stages created with returned context
+func WithClock(ctx context.Context, clock Clock) context.Context {
+	return context.WithValue(ctx, clockKey, clock)
+}
+
+// return clock set by `WithClock` or `SystemClock`
+func GetClock(ctx context.Context) Clock {
+	if clock, ok := ctx.Value(clockKey).(Clock); ok {
+		return clock
+	}
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
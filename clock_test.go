@@ -0,0 +1,118 @@
+package pipeline_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFakeClockPipeline() (context.Context, context.CancelFunc, *pipelinetest.FakeClock) {
+	clock := pipelinetest.NewFakeClock(time.Time{})
+	ctx, cancel := pl.NewPipeline(pl.WithClock(context.Background(), clock))
+	return ctx, cancel, clock
+}
+
+func TestGetClock(t *testing.T) {
+	assert.Equal(t, pl.SystemClock(), pl.GetClock(context.Background()))
+
+	clock := pipelinetest.NewFakeClock(time.Time{})
+	assert.Equal(t, clock, pl.GetClock(pl.WithClock(context.Background(), clock)))
+}
+
+func TestSignal_TryWaitClock(t *testing.T) {
+	clock := pipelinetest.NewFakeClock(time.Time{})
+	sig := pl.NewSignal()
+
+	res := make(chan bool)
+	go func() {
+		res <- sig.TryWaitClock(clock, time.Hour)
+	}()
+
+	clock.BlockUntilTimers(1)
+	clock.Advance(time.Hour)
+	assert.False(t, pipelinetest.CheckRead(t, res))
+}
+
+func TestClock_Batch(t *testing.T) {
+	ctx, cancel, clock := newFakeClockPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan int)
+	res := pl.Batch(ctx, input, 10, time.Minute)
+
+	input <- 1
+	input <- 2
+	clock.BlockUntilTimers(1)
+
+	clock.Advance(59 * time.Second)
+	pipelinetest.CheckPending(t, res)
+
+	clock.Advance(time.Second)
+	assert.Equal(t, []int{1, 2}, pipelinetest.CheckRead(t, res))
+}
+
+func TestClock_Retry(t *testing.T) {
+	ctx, cancel, clock := newFakeClockPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	attempts := atomic.Int32{}
+	input := make(chan int, 1)
+	input <- 1
+
+	res, _ := pl.TransformErr(ctx, 1, input, func(x int) (int, error) {
+		if attempts.Add(1) < 3 {
+			return 0, errTest
+		}
+		return x, nil
+	}, pl.Retry(pl.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}))
+
+	// backoffs are 1h and 2h
+	clock.BlockUntilTimers(1)
+	clock.Advance(time.Hour)
+	clock.BlockUntilTimers(1)
+	clock.Advance(time.Hour)
+	pipelinetest.CheckPending(t, res)
+	assert.Equal(t, int32(2), attempts.Load())
+
+	clock.Advance(time.Hour)
+	assert.Equal(t, 1, pipelinetest.CheckRead(t, res))
+}
+
+func TestClock_Throttle(t *testing.T) {
+	ctx, cancel, clock := newFakeClockPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 10)
+	res := pl.Throttle(ctx, seq, 1, 1) // one item per second
+
+	pipelinetest.CheckRead(t, res)
+
+	clock.BlockUntilTimers(1)
+	pipelinetest.CheckPending(t, res)
+
+	clock.Advance(time.Second)
+	pipelinetest.CheckRead(t, res)
+}
+
+func TestClock_DrainTimeout(t *testing.T) {
+	ctx, cancel, clock := newFakeClockPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	pl.Go(ctx, func() {
+		<-ctx.Done()
+	})
+
+	res := make(chan error)
+	go func() {
+		res <- pl.Drain(ctx, time.Hour)
+	}()
+
+	clock.BlockUntilTimers(1)
+	clock.Advance(time.Hour)
+	assert.ErrorIs(t, pipelinetest.CheckRead(t, res), pl.ErrDrainTimeout)
+}
@@ -2,34 +2,50 @@ package pipeline
 
 import "context"
 
-func Collect[T any](ctx context.Context, cb func() T) Oneshot[T] {
+// run `cb` in a separate goroutine and write its result to the returned oneshot,
+// oneshot is closed without a value if `cb` panics
+func Collect[T any](ctx context.Context, cb func() T, opts ...Option) Oneshot[T] {
+	st := newStage(ctx, "Collect", opts)
 	out := NewOneshot[T]()
+	spawn(ctx, st.origin(), func() {
+		defer st.end()
 
-	wg := getWaitGroup(ctx)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		out.Write(cb())
-	}()
+		var r T
+		err := st.call(0, func() error {
+			r = cb()
+			return nil
+		})
+		if err != nil {
+			st.fail()
+			reportPanic(ctx, err)
+			out.close() // note: nothing is written on panic
+			return
+		}
+
+		out.Write(r)
+	})
 	return out.Chan()
 }
 
-func CollectErr[T any](ctx context.Context, cb func() (T, error)) (Oneshot[T], Oneshot[error]) {
+func CollectErr[T any](ctx context.Context, cb func() (T, error), opts ...Option) (Oneshot[T], Oneshot[error]) {
+	st := newStage(ctx, "CollectErr", opts)
 	out := NewOneshot[T]()
-	cherr := NewOneshot[error]()
+	cherr := spawnErr(ctx, st.origin(), func() error {
+		defer st.end()
 
-	wg := getWaitGroup(ctx)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		v, err := cb()
-		if err != nil {
-			cherr.Write(err)
+		var r T
+		err := st.call(0, func() (err error) {
+			r, err = cb()
 			return
+		})
+		if err != nil {
+			st.fail()
+			return err
 		}
 
-		out.Write(v)
-	}()
+		out.Write(r)
+		return nil
+	})
 
-	return out.Chan(), cherr.Chan()
+	return out.Chan(), cherr
 }
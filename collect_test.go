@@ -5,12 +5,13 @@ import (
 	"testing"
 
 	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestCollect(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	nums := sequence(ctx, 0, 10)
 	sum := pl.Collect(ctx, func() int {
@@ -21,7 +22,7 @@ func TestCollect(t *testing.T) {
 		return sum
 	})
 
-	r := checkRead(t, sum)
+	r := pipelinetest.CheckRead(t, sum)
 	assert.Equal(t, r, 45)
 }
 
@@ -43,13 +44,13 @@ func TestCollect_DontStuck(t *testing.T) {
 		return sum
 	})
 
-	checkShutdown(t, cancel)
-	_ = checkRead(t, sum)
+	pipelinetest.CheckShutdown(t, cancel)
+	_ = pipelinetest.CheckRead(t, sum)
 }
 
 func TestCollectErr(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	nums := sequence(ctx, 0, 10)
 	sum, cherr := pl.CollectErr(ctx, func() (int, error) {
@@ -60,10 +61,10 @@ func TestCollectErr(t *testing.T) {
 		return sum, nil
 	})
 
-	r := checkRead(t, sum)
+	r := pipelinetest.CheckRead(t, sum)
 	assert.Equal(t, r, 45)
 
-	checkPending(t, cherr) // no errors
+	pipelinetest.CheckPending(t, cherr) // no errors
 }
 
 func TestCollectErr_PropagateError(t *testing.T) {
@@ -75,16 +76,16 @@ func TestCollectErr_PropagateError(t *testing.T) {
 		return 0, errTest
 	})
 
-	checkPending(t, sum)
-	checkPending(t, cherr)
+	pipelinetest.CheckPending(t, sum)
+	pipelinetest.CheckPending(t, cherr)
 
 	passError.Set()
 
-	err := checkRead(t, cherr)
+	err := pipelinetest.CheckRead(t, cherr)
 	assert.Equal(t, err, errTest)
 
-	checkShutdown(t, cancel)
+	pipelinetest.CheckShutdown(t, cancel)
 
 	// oneshot never closed
-	checkPending(t, sum)
+	pipelinetest.CheckPending(t, sum)
 }
@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// error of a fallible stage reported to the pipeline, see `Errors`
+type StageError struct {
+	Stage string // stage name, see `Name`
+	API   string // pipeline function that created the stage, e.g. "TransformErr"
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// return all errors reported by fallible stages (`TransformErr`, `ProcessErr`, `GoErr`, etc.)
+// joined by `errors.Join`, each one is wrapped in `*StageError`
+//
+// errors are collected in addition to stage error channels, so nothing is lost
+// even if only the first error is read; call it after `cancel` to get all errors;
+// returns `nil` if there are no errors or context was created without `NewPipeline`
+func Errors(ctx context.Context) error {
+	col, _ := ctx.Value(errorsKey).(*errorCollector)
+	if col == nil {
+		return nil
+	}
+
+	col.mu.Lock()
+	errs := slices.Clone(col.errs)
+	col.mu.Unlock()
+
+	return errors.Join(errs...)
+}
+
+type errorCollector struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+// add stage error to the pipeline collector, if any
+func reportError(ctx context.Context, api string, name string, err error) {
+	col, _ := ctx.Value(errorsKey).(*errorCollector)
+	if col == nil {
+		return
+	}
+
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	col.errs = append(col.errs, &StageError{Stage: name, API: api, Err: err})
+}
+
+func (st *stage) reportError(ctx context.Context, err error) {
+	reportError(ctx, st.api, st.name, err)
+}
@@ -0,0 +1,87 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrors(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	seq := sequence(ctx, 0, 10)
+	_, cherr := pl.ProcessErr(ctx, 2, seq, func(x int) error {
+		return fmt.Errorf("item %d: %w", x, errTest)
+	}, pl.Name("fail"))
+
+	// wait both workers to fail
+	pipelinetest.CheckRead(t, cherr)
+	pipelinetest.CheckRead(t, cherr)
+
+	_ = pl.GoErr(ctx, func() error {
+		return errTest
+	})
+
+	pipelinetest.CheckShutdown(t, cancel)
+
+	err := pl.Errors(ctx)
+	assert.ErrorIs(t, err, errTest)
+
+	// each worker sends its error
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	if assert.Len(t, errs, 3) {
+		var serr *pl.StageError
+		if assert.ErrorAs(t, errs[0], &serr) {
+			assert.Equal(t, "ProcessErr", serr.API)
+		}
+
+		stages := []string{}
+		for _, err := range errs {
+			stages = append(stages, err.(*pl.StageError).Stage)
+		}
+		assert.ElementsMatch(t, []string{"fail", "fail", "GoErr"}, stages)
+	}
+}
+
+func TestErrors_ContinueOnError(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	seq := sequence(ctx, 0, 20)
+	_, cherr := pl.ProcessErr(ctx, 1, seq, func(x int) error {
+		return errTest
+	}, pl.ContinueOnError[int](nil), pl.MaxErrors(10))
+
+	err := pipelinetest.CheckRead(t, cherr)
+	pipelinetest.CheckShutdown(t, cancel)
+
+	// dead letters are not reported, only the error that failed the stage
+	assert.Equal(t, "ProcessErr#1: "+err.Error(), pl.Errors(ctx).Error())
+	assert.ErrorIs(t, pl.Errors(ctx), pl.ErrTooManyErrors)
+}
+
+func TestErrors_Panic(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	res, _ := pl.GenerateErr(ctx, func(w pl.Writer[int]) error {
+		panic("boom")
+	}, pl.Name("gen"))
+	pipelinetest.ReadAll(t, res)
+
+	pipelinetest.CheckShutdown(t, cancel)
+
+	var perr *pl.PanicError
+	assert.ErrorAs(t, pl.Errors(ctx), &perr)
+	assert.ErrorContains(t, pl.Errors(ctx), "gen: panic in GenerateErr: boom")
+}
+
+func TestErrors_NoPipeline(t *testing.T) {
+	assert.NoError(t, pl.Errors(context.Background()))
+
+	ctx, cancel := pl.NewPipeline(context.Background())
+	pipelinetest.CheckShutdown(t, cancel)
+	assert.NoError(t, pl.Errors(ctx))
+}
@@ -0,0 +1,252 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// stage fails with this error (wrapping the last item error)
+// when `MaxErrors` or `MaxErrorRate` limit is exceeded
+var ErrTooManyErrors = errors.New("too many errors")
+
+// failed item sent to `DeadLetterQueue`
+type DeadLetter[T any] struct {
+	Item  T
+	Err   error
+	Stage string // name of the stage where the item has failed
+}
+
+// channel of failed items shared by one or more stages, see `ContinueOnError`
+//
+// channel is closed by `Close`, the owner of the queue must call it
+// when all stages that use the queue are finished
+type DeadLetterQueue[T any] struct {
+	ch chan DeadLetter[T]
+
+	mu     sync.RWMutex // held for reading while item is sent, so `Close` waits for it
+	closed bool
+}
+
+func NewDeadLetterQueue[T any](buffer int) *DeadLetterQueue[T] {
+	return &DeadLetterQueue[T]{
+		ch: make(chan DeadLetter[T], max(buffer, 0)),
+	}
+}
+
+func (q *DeadLetterQueue[T]) Chan() <-chan DeadLetter[T] {
+	return q.ch
+}
+
+// close the queue channel, it's safe to call it several times
+//
+// items that fail after the queue is closed are not sent,
+// they fail their stages as if `ContinueOnError` wasn't set
+func (q *DeadLetterQueue[T]) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.closed {
+		q.closed = true
+		close(q.ch)
+	}
+}
+
+func (q *DeadLetterQueue[T]) send(ctx context.Context, v any, err error, stage string) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return false
+	}
+	return Write(ctx, q.ch, DeadLetter[T]{v.(T), err, stage})
+}
+
+type deadLetterSink interface {
+	// returns `false` if `ctx` was cancelled or queue is closed
+	send(ctx context.Context, v any, err error, stage string) bool
+}
+
+type errorConfig struct {
+	queue     typedOpt // `*DeadLetterQueue[T]`, nil pointer if failed items are dropped
+	maxErrors int
+	maxRate   float64
+	minItems  int
+}
+
+// don't stop fallible stage (`TransformErr`, `ProcessErr`, etc.) on error,
+// send failed items with their errors to `dlq` and continue;
+// `dlq` can be nil to drop failed items
+//
+// stage fails as usual if `MaxErrors` or `MaxErrorRate` limit is exceeded
+func ContinueOnError[T any](dlq *DeadLetterQueue[T]) Option {
+	return func(cfg *stageConfig) {
+		cfg.errors.queue = cfg.typed("ContinueOnError", dlq)
+	}
+}
+
+// fail `ContinueOnError` stage when more than `n` items have failed
+func MaxErrors(n int) Option {
+	return func(cfg *stageConfig) {
+		cfg.errors.maxErrors = max(n, 0)
+	}
+}
+
+// fail `ContinueOnError` stage when the ratio of failed items to read items exceeds `rate`,
+// the limit is checked after at least `minItems` items are read
+func MaxErrorRate(rate float64, minItems int) Option {
+	return func(cfg *stageConfig) {
+		cfg.errors.maxRate = max(rate, 0)
+		cfg.errors.minItems = max(minItems, 0)
+	}
+}
+
+// failed items counters of `ContinueOnError` stage
+type errorBudget struct {
+	cfg    *errorConfig
+	dlq    deadLetterSink // nil if failed items are dropped
+	read   atomic.Int64
+	failed atomic.Int64
+}
+
+func (b *errorBudget) exceeded(failed int64) bool {
+	if b.cfg.maxErrors > 0 && failed > int64(b.cfg.maxErrors) {
+		return true
+	}
+
+	if b.cfg.maxRate > 0 {
+		read := b.read.Load()
+		if read >= int64(b.cfg.minItems) && float64(failed) > b.cfg.maxRate*float64(read) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// enable `ContinueOnError` mode for stage with input type `T`, if configured
+func setupDeadLetters[T any](st *stage) {
+	cfg := &st.cfg.errors
+	dlq, ok := typedOption[*DeadLetterQueue[T]](st, cfg.queue)
+	if !ok {
+		return
+	}
+
+	st.errs = &errorBudget{cfg: cfg}
+	if dlq != nil {
+		st.errs.dlq = dlq
+	}
+}
+
+// handle failed item: in `ContinueOnError` mode it's sent to dead letters and `nil` is returned,
+// otherwise the error that fails the stage is returned
+func (st *stage) deadLetter(ctx context.Context, v any, err error) error {
+	if st.errs == nil {
+		return err
+	}
+
+	if st.errs.exceeded(st.errs.failed.Add(1)) {
+		return fmt.Errorf("%w: %w", ErrTooManyErrors, err)
+	}
+
+	if st.errs.dlq != nil && !st.errs.dlq.send(ctx, v, err, st.name) {
+		return err // pipeline was cancelled or queue is closed, item is not delivered
+	}
+
+	return nil
+}
+
+// dead letter record of JSON-lines file
+type deadLetterRecord[T any] struct {
+	Stage string `json:"stage"`
+	Error string `json:"error"`
+	Item  T      `json:"item"`
+}
+
+// append dead letters to JSON-lines file at `path`, the file is created if needed
+//
+// each line holds "stage", "error" and "item" fields, items are encoded by `encoding/json`;
+// use `ReplayDeadLetters` to read the items back
+func SaveDeadLetters[T any](ctx context.Context, path string, in <-chan DeadLetter[T], opts ...Option) (Signal, Oneshot[error]) {
+	st := newStage(ctx, "SaveDeadLetters", opts)
+	input := newInput(st, in)
+	finished := NewSignal()
+
+	cherr := spawnErr(ctx, st.origin(), func() (err error) {
+		defer st.end()
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			st.fail()
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+
+		enc := json.NewEncoder(f)
+		for {
+			it, ok := input.read(ctx)
+			if !ok {
+				break
+			}
+
+			err := st.call(it.span, func() error {
+				rec := deadLetterRecord[T]{Stage: it.val.Stage, Item: it.val.Item}
+				if it.val.Err != nil {
+					rec.Error = it.val.Err.Error()
+				}
+				return enc.Encode(rec)
+			})
+			if err != nil {
+				st.fail()
+				return err
+			}
+		}
+
+		if ctx.Err() == nil {
+			finished.Set()
+		}
+		return nil
+	})
+
+	return finished.Chan(), cherr
+}
+
+// read items of JSON-lines file written by `SaveDeadLetters`
+func ReplayDeadLetters[T any](ctx context.Context, path string, opts ...Option) (<-chan T, Oneshot[error]) {
+	return generateErr(ctx, "ReplayDeadLetters", func(w Writer[T]) error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		scan := bufio.NewScanner(f)
+		scan.Buffer(nil, 64<<20)
+
+		for line := 1; scan.Scan(); line++ {
+			if len(scan.Bytes()) == 0 {
+				continue
+			}
+
+			var rec deadLetterRecord[T]
+			if err := json.Unmarshal(scan.Bytes(), &rec); err != nil {
+				return fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+
+			if !w.Write(rec.Item) {
+				return nil
+			}
+		}
+
+		return scan.Err()
+	}, opts)
+}
@@ -0,0 +1,235 @@
+package pipeline_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func failOdd(x int) (int, error) {
+	if x%2 != 0 {
+		return 0, errTest
+	}
+	return x, nil
+}
+
+func TestContinueOnError(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	dlq := pl.NewDeadLetterQueue[int](10)
+
+	seq := sequence(ctx, 0, 10)
+	res, cherr := pl.TransformErr(ctx, 2, seq, failOdd,
+		pl.Name("even"), pl.ContinueOnError(dlq))
+
+	vals := pipelinetest.ReadAll(t, res)
+	assert.ElementsMatch(t, []int{0, 2, 4, 6, 8}, vals)
+	dlq.Close() // stage is finished
+
+	var failed []int
+	pipelinetest.WithTimeout(t, "read dead letters", func() {
+		for dl := range dlq.Chan() {
+			assert.ErrorIs(t, dl.Err, errTest)
+			assert.Equal(t, "even", dl.Stage)
+			failed = append(failed, dl.Item)
+		}
+	})
+	assert.ElementsMatch(t, []int{1, 3, 5, 7, 9}, failed)
+
+	pipelinetest.CheckPending(t, cherr)
+}
+
+func TestContinueOnError_Shared(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	dlq := pl.NewDeadLetterQueue[int](10)
+
+	seq := sequence(ctx, 0, 6)
+	res, _ := pl.TransformErr(ctx, 1, seq, failOdd, pl.ContinueOnError(dlq))
+	finished, _ := pl.ProcessErr(ctx, 1, res, func(x int) error {
+		if x == 4 {
+			return errTest
+		}
+		return nil
+	}, pl.ContinueOnError(dlq))
+
+	pipelinetest.CheckSignaled(t, finished)
+	dlq.Close()
+
+	var failed []int
+	pipelinetest.WithTimeout(t, "read dead letters", func() {
+		for dl := range dlq.Chan() {
+			failed = append(failed, dl.Item)
+		}
+	})
+	assert.ElementsMatch(t, []int{1, 3, 5, 4}, failed)
+}
+
+func TestContinueOnError_Closed(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	dlq := pl.NewDeadLetterQueue[int](10)
+	dlq.Close()
+	dlq.Close()
+
+	// failed item can't be sent, so it fails the stage
+	seq := sequence(ctx, 0, 10)
+	_, cherr := pl.ProcessErr(ctx, 1, seq, func(x int) error {
+		_, err := failOdd(x)
+		return err
+	}, pl.ContinueOnError(dlq))
+
+	assert.ErrorIs(t, pipelinetest.CheckRead(t, cherr), errTest)
+}
+
+func TestContinueOnError_Drop(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 10)
+	res, cherr := pl.TransformOrderedErr(ctx, 4, 4, seq, failOdd,
+		pl.ContinueOnError[int](nil))
+
+	assert.Equal(t, []int{0, 2, 4, 6, 8}, pipelinetest.ReadAll(t, res))
+	pipelinetest.CheckPending(t, cherr)
+}
+
+func TestMaxErrors(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	dlq := pl.NewDeadLetterQueue[int](10)
+
+	seq := sequence(ctx, 0, 10)
+	_, cherr := pl.ProcessErr(ctx, 1, seq, func(x int) error {
+		_, err := failOdd(x)
+		return err
+	}, pl.ContinueOnError(dlq), pl.MaxErrors(2))
+
+	err := pipelinetest.CheckRead(t, cherr)
+	assert.ErrorIs(t, err, pl.ErrTooManyErrors)
+	assert.ErrorIs(t, err, errTest)
+
+	// items 1 and 3 are tolerated, 5 fails the stage
+	assert.Len(t, dlq.Chan(), 2)
+}
+
+func TestMaxErrorRate(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan int, 10)
+	_, cherr := pl.ProcessErr(ctx, 1, input, func(x int) error {
+		if x < 0 {
+			return errTest
+		}
+		return nil
+	}, pl.ContinueOnError[int](nil), pl.MaxErrorRate(0.25, 4))
+
+	// rate isn't checked until 4 items are read
+	input <- -1
+	input <- 1
+	input <- 2
+	input <- 3
+	pipelinetest.CheckPending(t, cherr)
+
+	input <- -1 // 2 of 5
+	assert.ErrorIs(t, pipelinetest.CheckRead(t, cherr), pl.ErrTooManyErrors)
+}
+
+func TestContinueOnError_TypeMismatch(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	dlq := pl.NewDeadLetterQueue[string](0)
+
+	assert.Panics(t, func() {
+		_, _ = pl.ProcessErr(ctx, 1, make(chan int), func(int) error {
+			return nil
+		}, pl.ContinueOnError(dlq))
+	})
+
+	// option of the context is ignored by stages of other types
+	seq := sequence(ctx, 0, 10)
+	_, cherr := pl.ProcessErr(pl.WithOptions(ctx, pl.ContinueOnError(dlq)), 1, seq, func(x int) error {
+		_, err := failOdd(x)
+		return err
+	})
+	assert.ErrorIs(t, pipelinetest.CheckRead(t, cherr), errTest)
+}
+
+func TestContinueOnError_DontStuck(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	dlq := pl.NewDeadLetterQueue[int](0)
+
+	seq := sequence(ctx, 0, 10)
+	res, _ := pl.TransformErr(ctx, 1, seq, failOdd, pl.ContinueOnError(dlq))
+	pipelinetest.CheckRead(t, res)
+
+	// nobody reads dead letters
+	pipelinetest.CheckShutdown(t, cancel)
+}
+
+func TestSaveDeadLetters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+
+	func() {
+		ctx, cancel := pl.NewPipeline(context.Background())
+		defer pipelinetest.CheckShutdown(t, cancel)
+
+		dlq := pl.NewDeadLetterQueue[int](0)
+		seq := sequence(ctx, 0, 6)
+		res, _ := pl.TransformErr(ctx, 1, seq, failOdd,
+			pl.Name("even"), pl.ContinueOnError(dlq))
+
+		saved, cherr := pl.SaveDeadLetters(ctx, path, dlq.Chan())
+		pipelinetest.ReadAll(t, res)
+		dlq.Close()
+		pipelinetest.CheckSignaled(t, saved)
+		pipelinetest.CheckPending(t, cherr)
+	}()
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if assert.Len(t, lines, 3) {
+		assert.JSONEq(t, `{"stage": "even", "error": "test", "item": 1}`, lines[0])
+	}
+
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	// replay items after the fix
+	replay, cherr := pl.ReplayDeadLetters[int](ctx, path)
+	res, _ := pl.TransformErr(ctx, 1, replay, func(x int) (int, error) {
+		return x * 10, nil
+	})
+	assert.Equal(t, []int{10, 30, 50}, pipelinetest.ReadAll(t, res))
+	pipelinetest.CheckPending(t, cherr)
+}
+
+func TestReplayDeadLetters_Errors(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	dir := t.TempDir()
+	_, cherr := pl.ReplayDeadLetters[int](ctx, filepath.Join(dir, "missing.jsonl"))
+	assert.ErrorIs(t, pipelinetest.CheckRead(t, cherr), os.ErrNotExist)
+
+	path := filepath.Join(dir, "bad.jsonl")
+	assert.NoError(t, os.WriteFile(path, []byte("{\"item\": 1}\nbad\n"), 0o644))
+
+	res, cherr := pl.ReplayDeadLetters[int](ctx, path)
+	assert.Equal(t, 1, pipelinetest.CheckRead(t, res))
+	assert.ErrorContains(t, pipelinetest.CheckRead(t, cherr), "bad.jsonl:2")
+}
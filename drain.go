@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// returned by `Drain` when the pipeline wasn't drained in time and was cancelled
+var ErrDrainTimeout = errors.New("drain timeout")
+
+type drainState struct {
+	draining SignalMut
+	once     sync.Once
+	cancel   context.CancelFunc
+}
+
+// gracefully stop the pipeline created by `NewPipeline`
+//
+// generators stop: `Writer.Write` of `Generate` returns `false`, custom sources
+// must check `Draining`; all items that are already in the pipeline are processed,
+// stages finish as their inputs are closed, then the pipeline is cancelled;
+// if goroutines are still running after `timeout`, the pipeline is cancelled
+// immediately and `ErrDrainTimeout` is returned
+//
+// like `cancel`, it must not be called from pipeline goroutines
+func Drain(ctx context.Context, timeout time.Duration) error {
+	drain, _ := ctx.Value(drainKey).(*drainState)
+	if drain == nil {
+		return nil
+	}
+
+	drain.once.Do(func() {
+		drain.draining.Set()
+	})
+
+	wg := getWaitGroup(ctx)
+	finished := NewSignal()
+	go func() {
+		wg.Wait()
+		finished.Set()
+	}()
+
+	var err error
+	if !finished.TryWaitClock(GetClock(ctx), timeout) {
+		err = ErrDrainTimeout
+	}
+
+	shutdown(wg.wg, drain.cancel)
+	return err
+}
+
+// signal that is triggered when `Drain` is called, sources should stop producing items then
+//
+// returns `nil` signal that is never triggered if context was created without `NewPipeline`
+func Draining(ctx context.Context) Signal {
+	drain, _ := ctx.Value(drainKey).(*drainState)
+	if drain == nil {
+		return nil
+	}
+	return drain.draining.Chan()
+}
@@ -0,0 +1,98 @@
+package pipeline_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrain(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	generated := atomic.Int32{}
+	seq := pl.Generate(ctx, func(w pl.Writer[int]) {
+		for k := 0; ; k++ {
+			if !w.Write(k) {
+				return
+			}
+			generated.Add(1)
+		}
+	})
+
+	res := pl.Transform(ctx, 4, seq, func(x int) int {
+		time.Sleep(time.Millisecond)
+		return x
+	}, pl.Buffer(10))
+
+	processed := atomic.Int32{}
+	finished := pl.Process(ctx, 1, res, func(int) {
+		processed.Add(1)
+	})
+
+	time.Sleep(10 * time.Millisecond)
+
+	pipelinetest.WithTimeout(t, "drain", func() {
+		assert.NoError(t, pl.Drain(ctx, time.Second))
+	})
+
+	// every generated item has reached the sink
+	assert.Equal(t, generated.Load(), processed.Load())
+	assert.Greater(t, processed.Load(), int32(0))
+	pipelinetest.CheckSignaled(t, finished)
+	assert.Error(t, ctx.Err())
+}
+
+func TestDrain_Timeout(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	stopped := atomic.Bool{}
+	pl.Go(ctx, func() {
+		<-ctx.Done() // ignores `Draining`
+		stopped.Store(true)
+	})
+
+	pipelinetest.WithTimeout(t, "drain", func() {
+		assert.ErrorIs(t, pl.Drain(ctx, 10*time.Millisecond), pl.ErrDrainTimeout)
+	})
+	assert.True(t, stopped.Load())
+}
+
+func TestDraining(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	// custom source
+	input := make(chan int)
+	pl.Go(ctx, func() {
+		defer close(input)
+		for k := 0; ; k++ {
+			select {
+			case input <- k:
+			case <-pl.Draining(ctx):
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+
+	finished := pl.Process(ctx, 2, input, func(int) {})
+
+	pipelinetest.CheckPending(t, pl.Draining(ctx))
+	pipelinetest.WithTimeout(t, "drain", func() {
+		assert.NoError(t, pl.Drain(ctx, time.Second))
+	})
+	pipelinetest.CheckSignaled(t, finished)
+}
+
+func TestDrain_NoPipeline(t *testing.T) {
+	assert.NoError(t, pl.Drain(context.Background(), 0))
+	pipelinetest.CheckPending(t, pl.Draining(context.Background()))
+}
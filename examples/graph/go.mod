@@ -0,0 +1,7 @@
+module github.com/greendwin/pipeline/examples/graph
+
+go 1.24.2
+
+replace github.com/greendwin/pipeline => ../..
+
+require github.com/greendwin/pipeline v0.1.0
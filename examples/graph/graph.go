@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/greendwin/pipeline"
+)
+
+func collect(baseUrl string) func(pipeline.Writer[string]) error {
+	return func(wr pipeline.Writer[string]) error {
+		for k := range 10 {
+			if !wr.Write(fmt.Sprintf("%s/v1/foo/%d", baseUrl, k)) {
+				return nil
+			}
+		}
+		return nil
+	}
+}
+
+func download(url string) (string, error) {
+	time.Sleep(100 * time.Millisecond)
+
+	if rand.Float32() < 0.05 {
+		return "", fmt.Errorf("failed: downloading %s", url)
+	}
+
+	log.Printf("download %q", url)
+	return fmt.Sprintf("<content:%q>", url), nil
+}
+
+func main() {
+	log.SetFlags(log.Ltime)
+
+	g := pipeline.NewGraph()
+
+	foo := pipeline.AddGenerate(g, "foo", collect("www.foo.com"))
+	bar := pipeline.AddGenerate(g, "bar", collect("www.bar.com"))
+	downloadIn, downloadOut := pipeline.AddTransform(g, "download", 5, download)
+	store := pipeline.AddProcess(g, "store", 1, func(cont string) error {
+		log.Printf("store %d bytes", len(cont))
+		return nil
+	})
+
+	pipeline.Connect(foo, downloadIn)
+	pipeline.Connect(bar, downloadIn)
+	pipeline.Connect(downloadOut, store)
+
+	// all stage errors are returned here, no need to wait each error channel
+	if err := g.Run(context.Background()); err != nil {
+		log.Printf("**STOPPING**: %v", err)
+		return
+	}
+
+	log.Println("done.")
+}
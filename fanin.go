@@ -1,9 +1,12 @@
 package pipeline
 
 import (
+	"container/heap"
 	"context"
 	"errors"
 	"reflect"
+	"sync"
+	"time"
 )
 
 var ErrChannelClosed = errors.New("channel closed")
@@ -55,18 +58,14 @@ func First[T any](ctx context.Context, in ...<-chan T) (Oneshot[T], Oneshot[erro
 	out := NewOneshot[T]()
 	cherr := NewOneshot[error]()
 
-	wg := getWaitGroup(ctx)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-
+	spawn(ctx, newOrigin("First"), func() {
 		v, err := WaitFirst(ctx, in...)
 		if err != nil {
 			cherr.Write(err)
 		} else {
 			out.Write(v)
 		}
-	}()
+	})
 
 	return out.Chan(), cherr.Chan()
 }
@@ -74,73 +73,259 @@ func First[T any](ctx context.Context, in ...<-chan T) (Oneshot[T], Oneshot[erro
 func FirstErr(ctx context.Context, errs ...<-chan error) Oneshot[error] {
 	cherr := NewOneshot[error]()
 
-	wg := getWaitGroup(ctx)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-
+	spawn(ctx, newOrigin("FirstErr"), func() {
 		err, waitErr := WaitFirst(ctx, errs...)
 		if waitErr != nil {
 			cherr.Write(waitErr)
 		} else {
 			cherr.Write(err)
 		}
-	}()
+	})
 
 	return cherr.Chan()
 }
 
+// merge input channels into one
+//
+// watermark of the output is the minimum of watermarks of the inputs
+// that are not closed yet, see `AssignWatermarks`; use `FanInWith` to pass options
 func FanIn[T any](ctx context.Context, in ...<-chan T) (<-chan T, Oneshot[error]) {
-	out := make(chan T)
+	return FanInWith(ctx, in)
+}
+
+// same as `FanIn`, but accepts stage options
+func FanInWith[T any](ctx context.Context, in []<-chan T, opts ...Option) (<-chan T, Oneshot[error]) {
+	st := newStage(ctx, "FanIn", opts)
+
+	inputs := make([]*input[T], len(in))
+	for k, ch := range in {
+		inputs[k] = newInput(st, ch)
+	}
+
+	out := newOutput[T](st)
 	cherr := NewOneshot[error]()
 
-	wg := getWaitGroup(ctx)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	// each input is read by its own goroutine, so item metadata is passed with it
+	items := make(chan fanInItem[T])
+	var readers sync.WaitGroup
+	readers.Add(len(in))
+	for k, input := range inputs {
+		spawn(ctx, st.origin(), func() {
+			defer readers.Done()
 
-		var cases []reflect.SelectCase
-		if len(in) <= 3 {
-			cases = make([]reflect.SelectCase, len(in)+1, 4)
-		} else {
-			cases = make([]reflect.SelectCase, len(in)+1)
-		}
+			for {
+				it, ok := input.read(ctx)
+				if !ok && ctx.Err() != nil {
+					return
+				}
+
+				if !Write(ctx, items, fanInItem[T]{input: k, it: it, closed: !ok}) || !ok {
+					return
+				}
+			}
+		})
+	}
 
-		for k, ch := range in {
-			cases[k].Dir = reflect.SelectRecv
-			cases[k].Chan = reflect.ValueOf(ch)
+	spawn(ctx, st.origin(), func() {
+		defer st.end()
+		defer readers.Wait()
+
+		wms := make([]time.Time, len(in)) // watermark of each input
+		pending := make([]int, len(in))   // 1 if input is not closed
+		for k := range pending {
+			pending[k] = 1
 		}
-		cases[len(in)].Dir = reflect.SelectRecv
-		cases[len(in)].Chan = reflect.ValueOf(ctx.Done())
 
-		for {
-			idx, v, ok := reflect.Select(cases)
-			if idx+1 == len(cases) {
-				// `ctx.Done()` was triggered
+		for left := len(in); left > 0; {
+			v, ok := Read(ctx, items)
+			if !ok {
 				cherr.Write(context.Cause(ctx))
 				return
 			}
 
-			if ok {
-				if !Write(ctx, out, v.Interface().(T)) {
-					// `ctx.Done()` was triggered
-					cherr.Write(context.Cause(ctx))
-					return
-				}
+			if v.closed {
+				pending[v.input] = 0
+				left -= 1
 				continue
 			}
 
-			if len(cases) == 2 {
-				// last input channel was closed, so close output channel
-				// note: only `ctx.Done()` is left
-				close(out)
+			it := v.it
+			if it.wm.After(wms[v.input]) {
+				wms[v.input] = it.wm
+			}
+			it.wm = minWatermark(wms, pending)
+
+			if !out.write(ctx, it) {
+				cherr.Write(context.Cause(ctx))
+				return
+			}
+		}
+
+		// last input channel was closed, so close output channel
+		out.close()
+	})
+
+	return out.ch, cherr.Chan()
+}
+
+type fanInItem[T any] struct {
+	input  int
+	it     item[T]
+	closed bool // input is closed, `it` is not set
+}
+
+// minimum watermark of the inputs that are not closed, zero if it's unknown
+func minWatermark(wms []time.Time, pending []int) (res time.Time) {
+	first := true
+	for k, wm := range wms {
+		if pending[k] == 0 {
+			continue // closed input doesn't hold back the others
+		}
+
+		if first || wm.Before(res) {
+			res = wm
+			first = false
+		}
+	}
+	return
+}
+
+// merge sorted input channels into one sorted channel
+//
+// item is written when every input that is not closed has an item to compare with,
+// so a slow input holds back the others; items that are equal are written in the order of inputs;
+// closed inputs are dropped as in `FanIn`, watermark of the output is the minimum of watermarks
+// of the inputs that are not closed
+//
+// if `less` panics, the panic is sent to the error channel and output is left open as on cancellation;
+// use `MergeSortedWith` to pass options
+func MergeSorted[T any](ctx context.Context, less func(a, b T) bool, in ...<-chan T) (<-chan T, Oneshot[error]) {
+	return MergeSortedWith(ctx, less, in)
+}
+
+// same as `MergeSorted`, but accepts stage options
+func MergeSortedWith[T any](ctx context.Context, less func(a, b T) bool, in []<-chan T, opts ...Option) (<-chan T, Oneshot[error]) {
+	st := newStage(ctx, "MergeSorted", opts)
+
+	inputs := make([]*input[T], len(in))
+	for k, ch := range in {
+		inputs[k] = newInput(st, ch)
+	}
+
+	out := newOutput[T](st)
+	cherr := NewOneshot[error]()
+
+	spawn(ctx, st.origin(), func() {
+		defer st.end()
+
+		heads := mergeHeads[T]{less: less}
+		wms := make([]time.Time, len(in)) // watermark of each input
+		pending := make([]int, len(in))   // 1 if input is not closed
+
+		// read the next item of input `k`, returns cancellation cause or `less` panic
+		next := func(k int) error {
+			it, ok := inputs[k].read(ctx)
+			if !ok {
+				if ctx.Err() != nil {
+					return context.Cause(ctx)
+				}
+
+				pending[k] = 0
+				return nil
+			}
+
+			if it.wm.After(wms[k]) {
+				wms[k] = it.wm
+			}
+			return st.call(it.span, func() error {
+				heap.Push(&heads, mergeHead[T]{it: it, input: k})
+				return nil
+			})
+		}
+
+		fail := func(err error) {
+			if _, isPanic := err.(*PanicError); isPanic {
+				st.fail()
+				st.reportError(ctx, err)
+			}
+			cherr.Write(err)
+		}
+
+		for k := range inputs {
+			pending[k] = 1
+			if err := next(k); err != nil {
+				fail(err)
 				return
 			}
+		}
 
-			// drop closed channel from select
-			cases = append(cases[:idx], cases[idx+1:]...)
+		for heads.Len() > 0 {
+			// note: the next item of the same input is not less than the head, so it's not needed
+			var head mergeHead[T]
+			err := st.call(heads.items[0].it.span, func() error {
+				head = heap.Pop(&heads).(mergeHead[T])
+				return nil
+			})
+			if err != nil {
+				fail(err)
+				return
+			}
+
+			head.it.wm = minWatermark(wms, pending)
+			if !out.write(ctx, head.it) {
+				cherr.Write(context.Cause(ctx))
+				return
+			}
+
+			if err := next(head.input); err != nil {
+				fail(err)
+				return
+			}
 		}
-	}()
 
-	return out, cherr.Chan()
+		// all input channels were closed
+		out.close()
+	})
+
+	return out.ch, cherr.Chan()
+}
+
+// first items of `MergeSorted` inputs, it implements `heap.Interface`
+type mergeHeads[T any] struct {
+	less  func(a, b T) bool
+	items []mergeHead[T]
+}
+
+type mergeHead[T any] struct {
+	it    item[T]
+	input int
+}
+
+func (h *mergeHeads[T]) Len() int {
+	return len(h.items)
+}
+
+func (h *mergeHeads[T]) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if h.less(a.it.val, b.it.val) {
+		return true
+	}
+	if h.less(b.it.val, a.it.val) {
+		return false
+	}
+	return a.input < b.input
+}
+
+func (h *mergeHeads[T]) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+}
+
+func (h *mergeHeads[T]) Push(x any) {
+	h.items = append(h.items, x.(mergeHead[T]))
+}
+
+func (h *mergeHeads[T]) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -13,7 +14,7 @@ func TestWaitFirst(t *testing.T) {
 	for index := range 10 {
 		t.Run(fmt.Sprintf("FirstRecv(%d)", index), func(t *testing.T) {
 			ctx, cancel := pl.NewPipeline(context.Background())
-			defer checkShutdown(t, cancel)
+			defer pipelinetest.CheckShutdown(t, cancel)
 
 			opts := make([]chan int, 10)
 			optsIn := make([]<-chan int, len(opts))
@@ -31,9 +32,9 @@ func TestWaitFirst(t *testing.T) {
 				finished.Set()
 			}()
 
-			checkPending(t, finished)
+			pipelinetest.CheckPending(t, finished)
 			opts[index] <- 42
-			checkSignaled(t, finished)
+			pipelinetest.CheckSignaled(t, finished)
 		})
 	}
 }
@@ -51,13 +52,13 @@ func TestWaitFirst_NeverStuck(t *testing.T) {
 		finished.Set()
 	}()
 
-	checkShutdown(t, cancel)
-	checkSignaled(t, finished)
+	pipelinetest.CheckShutdown(t, cancel)
+	pipelinetest.CheckSignaled(t, finished)
 }
 
 func TestWaitFirst_IgnoreClosedChannels(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	willClose1 := make(chan int)
 	willClose2 := make(chan int)
@@ -72,18 +73,18 @@ func TestWaitFirst_IgnoreClosedChannels(t *testing.T) {
 	}()
 
 	close(willClose1)
-	checkPending(t, finished)
+	pipelinetest.CheckPending(t, finished)
 
 	close(willClose2)
-	checkPending(t, finished)
+	pipelinetest.CheckPending(t, finished)
 
 	willSend <- 42
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 }
 
 func TestWaitFirst_ReturnNoneWhenAllClosed(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	willClose1 := make(chan int)
 	willClose2 := make(chan int)
@@ -96,10 +97,10 @@ func TestWaitFirst_ReturnNoneWhenAllClosed(t *testing.T) {
 	}()
 
 	close(willClose1)
-	checkPending(t, finished)
+	pipelinetest.CheckPending(t, finished)
 
 	close(willClose2)
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 }
 
 func TestWaitFirst_ReturnCauseError(t *testing.T) {
@@ -115,14 +116,14 @@ func TestWaitFirst_ReturnCauseError(t *testing.T) {
 		finished.Set()
 	}()
 
-	checkPending(t, finished)
+	pipelinetest.CheckPending(t, finished)
 	cancel(errTest)
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 }
 
 func TestFirst(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	opts := make([]chan int, 10)
 	optsIn := make([]<-chan int, len(opts))
@@ -133,15 +134,15 @@ func TestFirst(t *testing.T) {
 	}
 
 	res, cherr := pl.First(ctx, optsIn...)
-	checkPending(t, res)
+	pipelinetest.CheckPending(t, res)
 
 	close(opts[4])
-	checkPending(t, res)
+	pipelinetest.CheckPending(t, res)
 
 	close(opts[7])
-	checkPending(t, res)
+	pipelinetest.CheckPending(t, res)
 
-	withTimeout(t, "read channel", func() {
+	pipelinetest.WithTimeout(t, "read channel", func() {
 		opts[1] <- 42
 
 		v, ok := <-res
@@ -149,38 +150,38 @@ func TestFirst(t *testing.T) {
 		assert.Equal(t, v, 42)
 	})
 
-	checkPending(t, cherr) // no errors
+	pipelinetest.CheckPending(t, cherr) // no errors
 }
 func TestFirst_AllClosed(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	willClose1 := make(chan int)
 	willClose2 := make(chan int)
 
 	res, cherr := pl.First(ctx, willClose1, willClose2)
-	checkPending(t, res)
+	pipelinetest.CheckPending(t, res)
 
 	close(willClose1)
-	checkPending(t, res)
+	pipelinetest.CheckPending(t, res)
 
 	close(willClose2)
-	checkPending(t, res) // still pending, `Oneshot` channels are never closed
+	pipelinetest.CheckPending(t, res) // still pending, `Oneshot` channels are never closed
 
-	err := checkRead(t, cherr)
+	err := pipelinetest.CheckRead(t, cherr)
 	assert.ErrorIs(t, err, pl.ErrChannelClosed)
 }
 
 func TestFirstErr(t *testing.T) {
 	for _, first := range [...]bool{true, false} {
 		ctx, cancel := pl.NewPipeline(context.Background())
-		defer checkShutdown(t, cancel)
+		defer pipelinetest.CheckShutdown(t, cancel)
 
 		cherr1 := pl.NewOneshot[error]()
 		cherr2 := pl.NewOneshot[error]()
 
 		cherr := pl.FirstErr(ctx, cherr1.Chan(), cherr2.Chan())
-		checkPending(t, cherr)
+		pipelinetest.CheckPending(t, cherr)
 
 		if first {
 			cherr1.Write(errTest)
@@ -188,14 +189,14 @@ func TestFirstErr(t *testing.T) {
 			cherr2.Write(errTest)
 		}
 
-		err := checkRead(t, cherr)
+		err := pipelinetest.CheckRead(t, cherr)
 		assert.ErrorIs(t, err, errTest)
 	}
 }
 
 func TestFirstErr_AllClosed(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	willClose1 := make(chan error)
 	willClose2 := make(chan error)
@@ -203,17 +204,17 @@ func TestFirstErr_AllClosed(t *testing.T) {
 	cherr := pl.FirstErr(ctx, willClose1, willClose2)
 
 	close(willClose1)
-	checkPending(t, cherr) // wait second channel
+	pipelinetest.CheckPending(t, cherr) // wait second channel
 
 	close(willClose2)
 
-	err := checkRead(t, cherr)
+	err := pipelinetest.CheckRead(t, cherr)
 	assert.ErrorIs(t, err, pl.ErrChannelClosed)
 }
 
 func TestFanIn(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	seq1 := sequence(ctx, 0, 10)
 	seq2 := sequence(ctx, 10, 3)
@@ -221,7 +222,7 @@ func TestFanIn(t *testing.T) {
 
 	merged, cherr := pl.FanIn(ctx, seq1, seq2, seq3)
 
-	withTimeout(t, "read merged channel", func() {
+	pipelinetest.WithTimeout(t, "read merged channel", func() {
 		received := make([]bool, 20)
 		for v := range merged {
 			received[v] = true
@@ -232,7 +233,7 @@ func TestFanIn(t *testing.T) {
 		}
 	})
 
-	checkPending(t, cherr) // no errors
+	pipelinetest.CheckPending(t, cherr) // no errors
 }
 
 func TestFanIn_NeverStuckOnRecv(t *testing.T) {
@@ -244,10 +245,10 @@ func TestFanIn_NeverStuckOnRecv(t *testing.T) {
 
 	merged, cherr := pl.FanIn(ctx, neverSend1, neverSend2, neverSend3)
 
-	checkShutdown(t, cancel)
+	pipelinetest.CheckShutdown(t, cancel)
 
-	checkPending(t, merged)
-	err := checkRead(t, cherr)
+	pipelinetest.CheckPending(t, merged)
+	err := pipelinetest.CheckRead(t, cherr)
 	assert.ErrorIs(t, err, context.Canceled)
 }
 
@@ -260,15 +261,15 @@ func TestFanIn_NeverStuckOnSend(t *testing.T) {
 
 	merged, cherr := pl.FanIn(ctx, seq1, seq2, seq3)
 
-	withTimeout(t, "read single item", func() {
+	pipelinetest.WithTimeout(t, "read single item", func() {
 		// read one item to make sure that cancel will be triggerred inside `FanIn`
 		_ = <-merged
 	})
 
-	checkShutdown(t, cancel)
+	pipelinetest.CheckShutdown(t, cancel)
 
-	checkPending(t, merged) // should not be closed
-	err := checkRead(t, cherr)
+	pipelinetest.CheckPending(t, merged) // should not be closed
+	err := pipelinetest.CheckRead(t, cherr)
 	assert.ErrorIs(t, err, context.Canceled)
 }
 
@@ -280,15 +281,117 @@ func TestFanIn_PropagateCause(t *testing.T) {
 
 	merged, cherr := pl.FanIn(ctx, seq1, seq2)
 
-	withTimeout(t, "read one item", func() {
+	pipelinetest.WithTimeout(t, "read one item", func() {
 		// read one item to make sure that cancel will be inside `FanIn`
 		_ = <-merged
 	})
 
 	cancel(errTest)
 
-	err := checkRead(t, cherr)
+	err := pipelinetest.CheckRead(t, cherr)
 	assert.ErrorIs(t, err, errTest)
 
-	checkPending(t, merged) // would not close
+	pipelinetest.CheckPending(t, merged) // would not close
+}
+
+func intLess(a, b int) bool {
+	return a < b
+}
+
+func TestMergeSorted(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	even := pl.Filter(ctx, 1, sequence(ctx, 0, 10), func(v int) bool { return v%2 == 0 })
+	odd := pl.Filter(ctx, 1, sequence(ctx, 0, 10), func(v int) bool { return v%2 == 1 })
+	tail := sequence(ctx, 5, 10)
+
+	merged, cherr := pl.MergeSorted(ctx, intLess, even, odd, tail)
+
+	expected := []int{0, 1, 2, 3, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9, 10, 11, 12, 13, 14}
+	assert.Equal(t, expected, pipelinetest.ReadAll(t, merged))
+	pipelinetest.CheckPending(t, cherr) // no errors
+}
+
+func TestMergeSorted_WaitsForAllInputs(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input1 := make(chan int, 2)
+	input2 := make(chan int)
+	merged, _ := pl.MergeSorted(ctx, intLess, input1, input2)
+
+	input1 <- 3
+	input1 <- 5
+	pipelinetest.CheckPending(t, merged) // the second input can send a less item
+
+	input2 <- 1
+	assert.Equal(t, 1, pipelinetest.CheckRead(t, merged))
+	pipelinetest.CheckPending(t, merged)
+
+	// closed input doesn't hold back the others
+	close(input2)
+	assert.Equal(t, 3, pipelinetest.CheckRead(t, merged))
+	assert.Equal(t, 5, pipelinetest.CheckRead(t, merged))
+
+	close(input1)
+	pipelinetest.ReadAll(t, merged) // closed
+}
+
+func TestMergeSorted_Stable(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	type entry struct {
+		key   int
+		input string
+	}
+
+	input1 := make(chan entry, 2)
+	input2 := make(chan entry, 2)
+	input1 <- entry{1, "a"}
+	input1 <- entry{2, "a"}
+	input2 <- entry{1, "b"}
+	input2 <- entry{2, "b"}
+	close(input1)
+	close(input2)
+
+	merged, _ := pl.MergeSorted(ctx, func(a, b entry) bool { return a.key < b.key }, input2, input1)
+
+	// equal items are written in the order of inputs
+	assert.Equal(t, []entry{{1, "b"}, {1, "a"}, {2, "b"}, {2, "a"}}, pipelinetest.ReadAll(t, merged))
+}
+
+func TestMergeSorted_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+
+	merged, cherr := pl.MergeSorted(ctx, intLess, sequence(ctx, 0, 10), make(chan int))
+	cancel(errTest)
+
+	assert.ErrorIs(t, pipelinetest.CheckRead(t, cherr), errTest)
+	pipelinetest.CheckPending(t, merged) // would not close
+}
+
+func TestMergeSorted_Panic(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input1 := make(chan int, 2)
+	input2 := make(chan int, 1)
+	input1 <- 1
+	input1 <- 3
+	input2 <- 2
+
+	merged, cherr := pl.MergeSorted(ctx, func(a, b int) bool {
+		if a == 3 || b == 3 {
+			panic("bad item")
+		}
+		return a < b
+	}, input1, input2)
+
+	assert.Equal(t, 1, pipelinetest.CheckRead(t, merged))
+
+	var perr *pl.PanicError
+	assert.ErrorAs(t, pipelinetest.CheckRead(t, cherr), &perr)
+	pipelinetest.CheckPending(t, merged) // would not close
 }
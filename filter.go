@@ -0,0 +1,33 @@
+package pipeline
+
+import "context"
+
+// pass only items that satisfy `pred`, `threads` workers check items in parallel
+//
+// results are unordered
+func Filter[T any](ctx context.Context, threads int, in <-chan T, pred func(T) bool, opts ...Option) <-chan T {
+	out, _ := transform(ctx, "Filter", threads, in, false, opts, func(v T) (T, bool, error) {
+		return v, pred(v), nil
+	})
+	return out
+}
+
+func FilterErr[T any](ctx context.Context, threads int, in <-chan T, pred func(T) (bool, error), opts ...Option) (<-chan T, Oneshot[error]) {
+	return transform(ctx, "FilterErr", threads, in, true, opts, func(v T) (T, bool, error) {
+		ok, err := pred(v)
+		return v, ok, err
+	})
+}
+
+// transform items and drop the ones for which `cb` returns `false`
+func FilterMap[T any, U any](ctx context.Context, threads int, in <-chan T, cb func(T) (U, bool), opts ...Option) <-chan U {
+	out, _ := transform(ctx, "FilterMap", threads, in, false, opts, func(v T) (U, bool, error) {
+		r, ok := cb(v)
+		return r, ok, nil
+	})
+	return out
+}
+
+func FilterMapErr[T any, U any](ctx context.Context, threads int, in <-chan T, cb func(T) (U, bool, error), opts ...Option) (<-chan U, Oneshot[error]) {
+	return transform(ctx, "FilterMapErr", threads, in, true, opts, cb)
+}
@@ -0,0 +1,108 @@
+package pipeline_test
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"testing"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 10)
+	even := pl.Filter(ctx, 3, seq, func(x int) bool {
+		return x%2 == 0
+	})
+
+	res := pipelinetest.ReadAll(t, even)
+	slices.Sort(res)
+	assert.Equal(t, []int{0, 2, 4, 6, 8}, res)
+}
+
+func TestFilterErr(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 10)
+	even, cherr := pl.FilterErr(ctx, 3, seq, func(x int) (bool, error) {
+		return x%2 == 0, nil
+	})
+
+	res := pipelinetest.ReadAll(t, even)
+	slices.Sort(res)
+	assert.Equal(t, []int{0, 2, 4, 6, 8}, res)
+
+	pipelinetest.CheckPending(t, cherr)
+}
+
+func TestFilterErr_Propagate(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	seq := sequence(ctx, 0, 10)
+	even, cherr := pl.FilterErr(ctx, 1, seq, func(x int) (bool, error) {
+		if x == 5 {
+			return false, errTest
+		}
+		return x%2 == 0, nil
+	})
+
+	for _, v := range []int{0, 2, 4} {
+		assert.Equal(t, v, pipelinetest.CheckRead(t, even))
+	}
+
+	err := pipelinetest.CheckRead(t, cherr)
+	assert.Equal(t, errTest, err)
+	pipelinetest.CheckPending(t, even) // channel is not closed on error
+
+	pipelinetest.CheckShutdown(t, cancel)
+}
+
+func TestFilterMap(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan string, 4)
+	input <- "1"
+	input <- "foo"
+	input <- "3"
+	input <- ""
+	close(input)
+
+	nums := pl.FilterMap(ctx, 2, input, func(s string) (int, bool) {
+		v, err := strconv.Atoi(s)
+		return v, err == nil
+	})
+
+	res := pipelinetest.ReadAll(t, nums)
+	slices.Sort(res)
+	assert.Equal(t, []int{1, 3}, res)
+}
+
+func TestFilterMapErr(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	seq := sequence(ctx, 0, 10)
+	res, cherr := pl.FilterMapErr(ctx, 2, seq, func(x int) (string, bool, error) {
+		if x == 7 {
+			return "", false, errTest
+		}
+		return strconv.Itoa(x), x < 5, nil
+	})
+
+	pipelinetest.WithTimeout(t, "read values", func() {
+		for range 5 {
+			<-res
+		}
+	})
+
+	err := pipelinetest.CheckRead(t, cherr)
+	assert.Equal(t, errTest, err)
+
+	pipelinetest.CheckShutdown(t, cancel)
+}
@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// expand each item into any number of results written by `cb` to `Writer[U]`
+//
+// `Write` returns `false` if the pipeline is cancelled, `cb` must return then;
+// results are unordered between items, `threads` workers process items in parallel
+func FlatMap[T any, U any](ctx context.Context, threads int, in <-chan T, cb func(T, Writer[U]), opts ...Option) <-chan U {
+	out, _ := flatMap(ctx, "FlatMap", threads, in, false, opts, func(v T, wr Writer[U]) error {
+		cb(v, wr)
+		return nil
+	})
+	return out
+}
+
+func FlatMapErr[T any, U any](ctx context.Context, threads int, in <-chan T, cb func(T, Writer[U]) error, opts ...Option) (<-chan U, Oneshot[error]) {
+	return flatMap(ctx, "FlatMapErr", threads, in, true, opts, cb)
+}
+
+// writer that passes item span to all its results
+type itemWriter[T any] struct {
+	ctx  context.Context
+	out  *output[T]
+	span SpanID
+}
+
+func (w *itemWriter[T]) Write(val T) bool {
+	return w.out.write(w.ctx, item[T]{val: val, span: w.span})
+}
+
+func flatMap[T any, U any](ctx context.Context, api string, threads int, in <-chan T, fallible bool, opts []Option, cb func(T, Writer[U]) error) (<-chan U, Oneshot[error]) {
+	st := newStage(ctx, api, opts)
+	input := newInput(st, in)
+	out := newOutput[U](st)
+
+	var cherr OneshotMut[error]
+	if fallible {
+		cherr = NewOneshotGroup[error](threads) // each worker can send one error
+		setupDeadLetters[T](st)
+	}
+
+	hasError := atomic.Bool{}
+
+	workers := startWorkers(ctx, st, threads, input, func(it item[T]) bool {
+		err := st.call(it.span, func() error {
+			return cb(it.val, &itemWriter[U]{ctx, out, it.span})
+		})
+		if err != nil {
+			st.fail()
+
+			if !fallible {
+				reportItemPanic(ctx, it.val, err)
+				return true // skip the rest of failed item results
+			}
+
+			if err = st.deadLetter(ctx, it.val, err); err == nil {
+				return true // failed item is sent to dead letters
+			}
+
+			st.reportError(ctx, err)
+			hasError.Store(true)
+			cherr.tryWrite(err) // `Pool` and `Autoscale` can run more workers than `cherr` holds
+			return false
+		}
+
+		return ctx.Err() == nil
+	})
+
+	closeAfterAll(ctx, st, workers, &hasError, out)
+
+	return out.ch, cherr.Chan()
+}
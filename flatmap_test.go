@@ -0,0 +1,118 @@
+package pipeline_test
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlatMap(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 4)
+	res := pl.FlatMap(ctx, 2, seq, func(x int, wr pl.Writer[int]) {
+		// emit `x` copies of `x`
+		for range x {
+			if !wr.Write(x) {
+				return
+			}
+		}
+	})
+
+	vals := pipelinetest.ReadAll(t, res)
+	slices.Sort(vals)
+	assert.Equal(t, []int{1, 2, 2, 3, 3, 3}, vals)
+}
+
+func TestFlatMap_DontStuck(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	seq := sequence(ctx, 0, 4)
+	started := pl.NewSignal()
+	finished := pl.NewSignal()
+
+	// never read
+	_ = pl.FlatMap(ctx, 1, seq, func(x int, wr pl.Writer[int]) {
+		started.Set()
+		for wr.Write(x) {
+		}
+		finished.Set()
+	})
+
+	pipelinetest.CheckSignaled(t, started)
+	pipelinetest.CheckShutdown(t, cancel)
+	pipelinetest.CheckSignaled(t, finished)
+}
+
+func TestFlatMapErr(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 3)
+	res, cherr := pl.FlatMapErr(ctx, 2, seq, func(x int, wr pl.Writer[int]) error {
+		wr.Write(x)
+		wr.Write(-x)
+		return nil
+	})
+
+	vals := pipelinetest.ReadAll(t, res)
+	slices.Sort(vals)
+	assert.Equal(t, []int{-2, -1, 0, 0, 1, 2}, vals)
+
+	pipelinetest.CheckPending(t, cherr)
+}
+
+func TestFlatMapErr_Propagate(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	seq := sequence(ctx, 0, 3)
+	res, cherr := pl.FlatMapErr(ctx, 1, seq, func(x int, wr pl.Writer[int]) error {
+		if x == 1 {
+			return errTest
+		}
+		wr.Write(x)
+		return nil
+	})
+
+	assert.Equal(t, 0, pipelinetest.CheckRead(t, res))
+
+	err := pipelinetest.CheckRead(t, cherr)
+	assert.Equal(t, errTest, err)
+	pipelinetest.CheckPending(t, res)
+
+	pipelinetest.CheckShutdown(t, cancel)
+}
+
+func TestFlatMapErr_Pool(t *testing.T) {
+	ctx, cancel, p := newPanicPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	pool := &pl.WorkerPool{}
+	pool.Resize(4) // more workers than `threads`
+
+	var active atomic.Int32
+	failed := pl.NewSignal()
+	res, cherr := pl.FlatMapErr(ctx, 1, sequence(ctx, 0, 4), func(x int, wr pl.Writer[int]) error {
+		if active.Add(1) == 4 {
+			failed.Set()
+		}
+		failed.Wait() // all workers fail
+		return errTest
+	}, pl.Pool(pool))
+
+	assert.ErrorIs(t, pipelinetest.CheckRead(t, cherr), errTest)
+	pipelinetest.WithTimeout(t, "wait workers", func() {
+		for pool.Running() != 0 {
+			time.Sleep(time.Millisecond)
+		}
+	})
+	pipelinetest.CheckPending(t, res)
+	assert.Empty(t, p.Get())
+}
@@ -7,33 +7,61 @@ type Writer[T any] interface {
 }
 
 type channel[T any] struct {
-	ctx context.Context
-	ch  chan T
+	ctx   context.Context
+	out   *output[T]
+	drain Signal // stop writing on `Drain`
+}
+
+func newChannel[T any](ctx context.Context, st *stage) *channel[T] {
+	return &channel[T]{ctx, newOutput[T](st), Draining(ctx)}
 }
 
 func (ch *channel[T]) Write(val T) bool {
-	return Write(ch.ctx, ch.ch, val)
+	if ch.drain.TryWait(0) {
+		return false
+	}
+	return ch.out.write(ch.ctx, newItem(ch.out.st, val))
+}
+
+func Generate[T any](ctx context.Context, cb func(Writer[T]), opts ...Option) <-chan T {
+	st := newStage(ctx, "Generate", opts)
+	out := newChannel[T](ctx, st)
+	spawn(ctx, st.origin(), func() {
+		defer st.end()
+		defer out.out.close()
+
+		err := st.call(0, func() error {
+			cb(out)
+			return nil
+		})
+		if err != nil {
+			st.fail()
+			reportPanic(ctx, err)
+		}
+	})
+
+	return out.out.ch
 }
 
-func Generate[T any](ctx context.Context, cb func(Writer[T])) <-chan T {
-	out := channel[T]{ctx, make(chan T)}
-	wg := getWaitGroup(ctx)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		defer close(out.ch)
-		cb(&out)
-	}()
-
-	return out.ch
+func GenerateErr[T any](ctx context.Context, cb func(Writer[T]) error, opts ...Option) (<-chan T, Oneshot[error]) {
+	return generateErr(ctx, "GenerateErr", cb, opts)
 }
 
-func GenerateErr[T any](ctx context.Context, cb func(Writer[T]) error) (<-chan T, Oneshot[error]) {
-	out := channel[T]{ctx, make(chan T)}
-	cherr := GoErr(ctx, func() error {
-		defer close(out.ch)
-		return cb(&out)
+func generateErr[T any](ctx context.Context, api string, cb func(Writer[T]) error, opts []Option) (<-chan T, Oneshot[error]) {
+	st := newStage(ctx, api, opts)
+	out := newChannel[T](ctx, st)
+	cherr := spawnErr(ctx, st.origin(), func() error {
+		defer st.end()
+		defer out.out.close()
+
+		err := st.call(0, func() error {
+			return cb(out)
+		})
+		if err != nil {
+			st.fail()
+		}
+		return err
 	})
 
-	return out.ch, cherr
+	return out.out.ch, cherr
 }
@@ -5,12 +5,13 @@ import (
 	"testing"
 
 	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestGenerate(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	squares := pl.Generate(ctx, func(wr pl.Writer[int]) {
 		for k := range 10 {
@@ -20,7 +21,7 @@ func TestGenerate(t *testing.T) {
 		}
 	})
 
-	withTimeout(t, "read seq", func() {
+	pipelinetest.WithTimeout(t, "read seq", func() {
 		index := 0
 		for val := range squares {
 			assert.Equal(t, val, index*index)
@@ -40,13 +41,13 @@ func TestGenerateDontStuck(t *testing.T) {
 		finished.Set()
 	})
 
-	checkShutdown(t, cancel)
-	checkSignaled(t, finished)
+	pipelinetest.CheckShutdown(t, cancel)
+	pipelinetest.CheckSignaled(t, finished)
 }
 
 func TestGenerateErr(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	finished := pl.NewSignal()
 	squares, cherr := pl.GenerateErr(ctx, func(wr pl.Writer[int]) error {
@@ -58,7 +59,7 @@ func TestGenerateErr(t *testing.T) {
 		return nil
 	})
 
-	withTimeout(t, "read seq", func() {
+	pipelinetest.WithTimeout(t, "read seq", func() {
 		index := 0
 		for val := range squares {
 			assert.Equal(t, val, index*index)
@@ -66,13 +67,13 @@ func TestGenerateErr(t *testing.T) {
 		}
 	})
 
-	checkPending(t, cherr)
-	checkSignaled(t, finished)
+	pipelinetest.CheckPending(t, cherr)
+	pipelinetest.CheckSignaled(t, finished)
 }
 
 func TestGenerateErrPropagate(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	partial, cherr := pl.GenerateErr(ctx, func(wr pl.Writer[int]) error {
 		for k := range 5 {
@@ -92,11 +93,11 @@ func TestGenerateErrPropagate(t *testing.T) {
 		finished.Set()
 	}()
 
-	err := checkRead(t, cherr)
+	err := pipelinetest.CheckRead(t, cherr)
 	assert.Equal(t, err, errTest)
 
 	// note: `partial` channel must be closed for this
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 }
 
 func TestGenerateErrDontStuck(t *testing.T) {
@@ -111,8 +112,8 @@ func TestGenerateErrDontStuck(t *testing.T) {
 	})
 
 	// shutdown execution to unblock write
-	checkShutdown(t, cancel)
+	pipelinetest.CheckShutdown(t, cancel)
 
-	checkPending(t, cherr)
-	checkSignaled(t, finished)
+	pipelinetest.CheckPending(t, cherr)
+	pipelinetest.CheckSignaled(t, finished)
 }
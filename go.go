@@ -5,24 +5,49 @@ import "context"
 // spawn goroutine, tracking spawn count
 // make sure that it will exit on shutdown
 func Go(ctx context.Context, cb func()) {
+	spawn(ctx, newOrigin("Go"), cb)
+}
+
+// spawn goroutine that can fail
+func GoErr(ctx context.Context, cb func() error) Oneshot[error] {
+	return spawnErr(ctx, newOrigin("GoErr"), cb)
+}
+
+// `Go` implementation, `o.api` is reported in `PanicError`
+func spawn(ctx context.Context, o origin, cb func()) {
 	wg := getWaitGroup(ctx)
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		cb()
+		defer wg.tasks.add(ctx, o)()
+
+		if perr := catchPanic(o.api, cb); perr != nil {
+			reportPanic(ctx, perr)
+		}
 	}()
 }
 
-// spawn goroutine that can fail
-func GoErr(ctx context.Context, cb func() error) Oneshot[error] {
+// `GoErr` implementation, panic is sent to the error channel,
+// error is reported to the pipeline collector as an error of `o.stage` (or `o.api`)
+func spawnErr(ctx context.Context, o origin, cb func() error) Oneshot[error] {
 	wg := getWaitGroup(ctx)
 	cherr := NewOneshot[error]()
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
+		defer wg.tasks.add(ctx, o)()
+
+		var err error
+		if perr := catchPanic(o.api, func() { err = cb() }); perr != nil {
+			err = perr
+		}
 
-		err := cb()
 		if err != nil {
+			name := o.stage
+			if name == "" {
+				name = o.api
+			}
+			reportError(ctx, o.api, name, err)
 			cherr.Write(err)
 		}
 	}()
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -13,19 +14,19 @@ var errTest = errors.New("test")
 
 func TestPipelineGo(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	started := pl.NewSignal()
 	pl.Go(ctx, func() {
 		started.Set()
 	})
 
-	checkSignaled(t, started)
+	pipelinetest.CheckSignaled(t, started)
 }
 
 func TestPipelineGoErr(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	started := pl.NewSignal()
 	exit := pl.NewSignal()
@@ -36,12 +37,12 @@ func TestPipelineGoErr(t *testing.T) {
 		return errTest
 	})
 
-	checkSignaled(t, started)
-	checkPending(t, cherr)
+	pipelinetest.CheckSignaled(t, started)
+	pipelinetest.CheckPending(t, cherr)
 
 	exit.Set()
 
-	err := checkRead(t, cherr)
+	err := pipelinetest.CheckRead(t, cherr)
 	assert.Equal(t, err, errTest)
 }
 
@@ -61,7 +62,7 @@ func TestPipelineShutdownGoErr(t *testing.T) {
 	}()
 
 	// wait for all spawned goroutines to exit
-	checkPending(t, shutdownFinished)
+	pipelinetest.CheckPending(t, shutdownFinished)
 	exit.Set()
-	checkSignaled(t, shutdownFinished)
+	pipelinetest.CheckSignaled(t, shutdownFinished)
 }
@@ -0,0 +1,304 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// graph validation error, see `Graph.Validate`
+var ErrInvalidGraph = errors.New("invalid graph")
+
+// declarative pipeline: register named stages with `AddSource`, `AddStage`, `AddSink`
+// (or `AddGenerate`, `AddTransform`, `AddProcess`), connect their ports with `Connect`
+// and start all of them with `Run`
+//
+// each stage has at most one input and one output port; an output is connected
+// to exactly one input, several outputs connected to one input are merged by `FanIn`
+type Graph struct {
+	nodes []*graphNode
+	names map[string]*graphNode
+	errs  []error // errors found while building, reported by `Validate`
+}
+
+type graphNode struct {
+	name    string
+	hasIn   bool
+	hasOut  bool
+	sources []*graphNode // nodes connected to the input
+	target  *graphNode   // node connected to the output
+
+	// start the stage, `in` is `<-chan T` of the input type
+	start func(ctx context.Context, in []any) (out any, finished Signal, cherr Oneshot[error])
+}
+
+// typed input port of a graph stage
+type InPort[T any] struct {
+	g    *Graph
+	node *graphNode
+}
+
+// typed output port of a graph stage
+type OutPort[T any] struct {
+	g    *Graph
+	node *graphNode
+}
+
+func NewGraph() *Graph {
+	return &Graph{names: make(map[string]*graphNode)}
+}
+
+func (g *Graph) addNode(name string, hasIn, hasOut bool) *graphNode {
+	node := &graphNode{name: name, hasIn: hasIn, hasOut: hasOut}
+	if _, ok := g.names[name]; ok {
+		g.errs = append(g.errs, fmt.Errorf("%w: duplicate stage name %q", ErrInvalidGraph, name))
+	} else {
+		g.names[name] = node
+	}
+	g.nodes = append(g.nodes, node)
+	return node
+}
+
+// register a stage without input, `run` must start it with the given context (e.g. `GenerateErr`)
+//
+// `run` context holds the stage name, so it's reported by `Stats`, `Errors` and tracer;
+// `cherr` can be nil for stages that can't fail
+func AddSource[U any](g *Graph, name string, run func(ctx context.Context) (<-chan U, Oneshot[error])) OutPort[U] {
+	node := g.addNode(name, false, true)
+	node.start = func(ctx context.Context, _ []any) (any, Signal, Oneshot[error]) {
+		out, cherr := run(ctx)
+		return out, nil, cherr
+	}
+	return OutPort[U]{g, node}
+}
+
+// register a stage with input and output, e.g. `TransformErr` or `Batch`
+func AddStage[T any, U any](g *Graph, name string, run func(ctx context.Context, in <-chan T) (<-chan U, Oneshot[error])) (InPort[T], OutPort[U]) {
+	node := g.addNode(name, true, true)
+	node.start = func(ctx context.Context, in []any) (any, Signal, Oneshot[error]) {
+		out, cherr := run(ctx, mergeInputs[T](ctx, name, in))
+		return out, nil, cherr
+	}
+	return InPort[T]{g, node}, OutPort[U]{g, node}
+}
+
+// register a stage without output, e.g. `ProcessErr`; the graph is finished
+// when `finished` signals of all sinks are triggered
+func AddSink[T any](g *Graph, name string, run func(ctx context.Context, in <-chan T) (Signal, Oneshot[error])) InPort[T] {
+	node := g.addNode(name, true, false)
+	node.start = func(ctx context.Context, in []any) (any, Signal, Oneshot[error]) {
+		finished, cherr := run(ctx, mergeInputs[T](ctx, name, in))
+		return nil, finished, cherr
+	}
+	return InPort[T]{g, node}
+}
+
+// `GenerateErr` stage of a graph
+func AddGenerate[T any](g *Graph, name string, cb func(Writer[T]) error, opts ...Option) OutPort[T] {
+	return AddSource(g, name, func(ctx context.Context) (<-chan T, Oneshot[error]) {
+		return GenerateErr(ctx, cb, opts...)
+	})
+}
+
+// `TransformErr` stage of a graph
+func AddTransform[T any, U any](g *Graph, name string, threads int, cb func(T) (U, error), opts ...Option) (InPort[T], OutPort[U]) {
+	return AddStage(g, name, func(ctx context.Context, in <-chan T) (<-chan U, Oneshot[error]) {
+		return TransformErr(ctx, threads, in, cb, opts...)
+	})
+}
+
+// `ProcessErr` stage of a graph
+func AddProcess[T any](g *Graph, name string, threads int, cb func(T) error, opts ...Option) InPort[T] {
+	return AddSink(g, name, func(ctx context.Context, in <-chan T) (Signal, Oneshot[error]) {
+		return ProcessErr(ctx, threads, in, cb, opts...)
+	})
+}
+
+// connect stage output to stage input, problems are reported by `Validate`
+func Connect[T any](from OutPort[T], to InPort[T]) {
+	g := from.g
+	switch {
+	case from.node == nil || to.node == nil:
+		panic("graph port is not initialized")
+	case g != to.g:
+		g.errs = append(g.errs, fmt.Errorf("%w: stages %q and %q belong to different graphs", ErrInvalidGraph, from.node.name, to.node.name))
+	case from.node.target != nil:
+		g.errs = append(g.errs, fmt.Errorf("%w: output of %q is already connected to %q", ErrInvalidGraph, from.node.name, from.node.target.name))
+	default:
+		from.node.target = to.node
+		to.node.sources = append(to.node.sources, from.node)
+	}
+}
+
+// check that all ports are connected and there are no cycles
+//
+// returns all problems joined by `errors.Join`, each one wraps `ErrInvalidGraph`
+func (g *Graph) Validate() error {
+	_, err := g.order()
+	return err
+}
+
+// return nodes in topological order
+func (g *Graph) order() ([]*graphNode, error) {
+	errs := append([]error(nil), g.errs...)
+
+	for _, node := range g.nodes {
+		if node.hasIn && len(node.sources) == 0 {
+			errs = append(errs, fmt.Errorf("%w: input of %q is not connected", ErrInvalidGraph, node.name))
+		}
+		if node.hasOut && node.target == nil {
+			errs = append(errs, fmt.Errorf("%w: output of %q is not connected", ErrInvalidGraph, node.name))
+		}
+	}
+
+	// Kahn's algorithm, nodes that are left belong to cycles
+	inDegree := make(map[*graphNode]int, len(g.nodes))
+	var order []*graphNode
+	for _, node := range g.nodes {
+		inDegree[node] = len(node.sources)
+		if len(node.sources) == 0 {
+			order = append(order, node)
+		}
+	}
+
+	for k := 0; k < len(order); k++ {
+		if next := order[k].target; next != nil {
+			inDegree[next] -= 1
+			if inDegree[next] == 0 {
+				order = append(order, next)
+			}
+		}
+	}
+
+	reported := make(map[*graphNode]bool)
+	for _, node := range g.nodes {
+		if inDegree[node] == 0 || reported[node] {
+			continue
+		}
+
+		// each node has a single output, so following them from the node leads to a cycle
+		seen := make(map[*graphNode]bool)
+		for !seen[node] {
+			seen[node] = true
+			node = node.target
+		}
+
+		if reported[node] {
+			continue
+		}
+
+		path := []string{node.name}
+		for next := node.target; ; next = next.target {
+			reported[next] = true
+			path = append(path, next.name)
+			if next == node {
+				break
+			}
+		}
+
+		errs = append(errs, fmt.Errorf("%w: cycle %s", ErrInvalidGraph, strings.Join(path, " -> ")))
+	}
+
+	return order, errors.Join(errs...)
+}
+
+// validate the graph, start all stages within a new pipeline and wait until
+// all sinks are finished or any stage fails
+//
+// on failure the pipeline is cancelled and all errors reported by stages are returned
+// (see `Errors`), `context.Cause(ctx)` is returned if `ctx` is cancelled
+func (g *Graph) Run(ctx context.Context) error {
+	order, err := g.order()
+	if err != nil {
+		return err
+	}
+
+	pctx, cancel := NewPipeline(ctx)
+	defer cancel()
+
+	outs := make(map[*graphNode]any, len(order))
+	var sinks []Signal
+	var errNodes []*graphNode
+	var errChans []Oneshot[error]
+
+	for _, node := range order {
+		in := make([]any, len(node.sources))
+		for k, src := range node.sources {
+			in[k] = outs[src]
+		}
+
+		out, finished, cherr := node.start(WithStageName(pctx, node.name), in)
+		if node.hasOut {
+			outs[node] = out
+		} else {
+			sinks = append(sinks, finished)
+		}
+
+		if cherr != nil {
+			errNodes = append(errNodes, node)
+			errChans = append(errChans, cherr)
+		}
+	}
+
+	err = waitGraph(pctx, sinks, errNodes, errChans)
+	cancel()
+
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
+	if all := Errors(pctx); all != nil {
+		return all
+	}
+	return err
+}
+
+// wait all `sinks` or the first error, error of a stage that doesn't report
+// to the pipeline collector is wrapped to `*StageError`
+func waitGraph(ctx context.Context, sinks []Signal, errNodes []*graphNode, errChans []Oneshot[error]) error {
+	cases := make([]reflect.SelectCase, 0, len(sinks)+len(errChans)+1)
+	for _, sig := range sinks {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(sig)})
+	}
+	for _, cherr := range errChans {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cherr)})
+	}
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+
+	for pending := len(sinks); pending > 0; {
+		idx, v, _ := reflect.Select(cases)
+
+		switch {
+		case idx+1 == len(cases):
+			return context.Cause(ctx)
+
+		case idx < len(sinks):
+			pending -= 1
+			cases[idx].Chan = reflect.Value{} // never selected again
+
+		default:
+			node := errNodes[idx-len(sinks)]
+			err, _ := v.Interface().(error)
+			return &StageError{Stage: node.name, API: "Graph", Err: err}
+		}
+	}
+
+	return nil
+}
+
+// pass the input channel or merge several ones
+func mergeInputs[T any](ctx context.Context, name string, in []any) <-chan T {
+	if len(in) == 1 {
+		return in[0].(<-chan T)
+	}
+
+	chans := make([]<-chan T, len(in))
+	for k, ch := range in {
+		chans[k] = ch.(<-chan T)
+	}
+
+	out, _ := FanInWith(withoutOptions(ctx), chans, Name(name+"/merge")) // error means cancellation
+	return out
+}
@@ -0,0 +1,174 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func generateRange(start, count int) func(pl.Writer[int]) error {
+	return func(w pl.Writer[int]) error {
+		for k := range count {
+			if !w.Write(start + k) {
+				return nil
+			}
+		}
+		return nil
+	}
+}
+
+func TestGraph(t *testing.T) {
+	g := pl.NewGraph()
+
+	nums := pl.AddGenerate(g, "nums", generateRange(0, 10))
+	squareIn, squareOut := pl.AddTransform(g, "square", 4, func(x int) (int, error) {
+		return x * x, nil
+	})
+
+	var mu sync.Mutex
+	var res []int
+	store := pl.AddProcess(g, "store", 1, func(x int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		res = append(res, x)
+		return nil
+	})
+
+	pl.Connect(nums, squareIn)
+	pl.Connect(squareOut, store)
+
+	pipelinetest.WithTimeout(t, "run graph", func() {
+		assert.NoError(t, g.Run(context.Background()))
+	})
+	assert.ElementsMatch(t, []int{0, 1, 4, 9, 16, 25, 36, 49, 64, 81}, res)
+}
+
+func TestGraph_Merge(t *testing.T) {
+	g := pl.NewGraph()
+
+	a := pl.AddGenerate(g, "a", generateRange(0, 3))
+	b := pl.AddGenerate(g, "b", generateRange(10, 3))
+
+	batchIn, batchOut := pl.AddStage(g, "batch", func(ctx context.Context, in <-chan int) (<-chan []int, pl.Oneshot[error]) {
+		return pl.Batch(ctx, in, 100, 0), nil
+	})
+
+	var res []int
+	sink := pl.AddSink(g, "sink", func(ctx context.Context, in <-chan []int) (pl.Signal, pl.Oneshot[error]) {
+		return pl.Process(ctx, 1, in, func(batch []int) {
+			res = append(res, batch...)
+		}), nil
+	})
+
+	// both outputs are merged into one input
+	pl.Connect(a, batchIn)
+	pl.Connect(b, batchIn)
+	pl.Connect(batchOut, sink)
+
+	pipelinetest.WithTimeout(t, "run graph", func() {
+		assert.NoError(t, g.Run(context.Background()))
+	})
+	assert.ElementsMatch(t, []int{0, 1, 2, 10, 11, 12}, res)
+}
+
+func TestGraph_Error(t *testing.T) {
+	g := pl.NewGraph()
+
+	nums := pl.AddGenerate(g, "nums", generateRange(0, 100))
+	failIn, failOut := pl.AddTransform(g, "fail", 2, func(x int) (int, error) {
+		if x == 5 {
+			return 0, errTest
+		}
+		return x, nil
+	})
+	sink := pl.AddProcess(g, "sink", 1, func(int) error { return nil })
+
+	pl.Connect(nums, failIn)
+	pl.Connect(failOut, sink)
+
+	var err error
+	pipelinetest.WithTimeout(t, "run graph", func() {
+		err = g.Run(context.Background())
+	})
+
+	assert.ErrorIs(t, err, errTest)
+	assert.ErrorContains(t, err, "fail: test")
+
+	var serr *pl.StageError
+	if assert.ErrorAs(t, err, &serr) {
+		assert.Equal(t, "fail", serr.Stage)
+	}
+}
+
+func TestGraph_CustomError(t *testing.T) {
+	g := pl.NewGraph()
+
+	errCh := make(chan error, 1)
+	errCh <- errTest
+
+	// error channel that doesn't report to the pipeline collector
+	src := pl.AddSource(g, "src", func(ctx context.Context) (<-chan int, pl.Oneshot[error]) {
+		return make(chan int), errCh
+	})
+	sink := pl.AddProcess(g, "sink", 1, func(int) error { return nil })
+	pl.Connect(src, sink)
+
+	err := g.Run(context.Background())
+	assert.ErrorIs(t, err, errTest)
+	assert.EqualError(t, err, "src: test")
+}
+
+func TestGraph_Cancel(t *testing.T) {
+	g := pl.NewGraph()
+
+	src := pl.AddSource(g, "src", func(ctx context.Context) (<-chan int, pl.Oneshot[error]) {
+		return make(chan int), nil // never finished
+	})
+	sink := pl.AddProcess(g, "sink", 1, func(int) error { return nil })
+	pl.Connect(src, sink)
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errTest)
+
+	pipelinetest.WithTimeout(t, "run graph", func() {
+		assert.ErrorIs(t, g.Run(ctx), errTest)
+	})
+}
+
+func TestGraph_Validate(t *testing.T) {
+	g := pl.NewGraph()
+
+	src := pl.AddGenerate(g, "src", generateRange(0, 1))
+	aIn, aOut := pl.AddTransform(g, "a", 1, func(x int) (int, error) { return x, nil })
+	bIn, bOut := pl.AddTransform(g, "b", 1, func(x int) (int, error) { return x, nil })
+	_ = pl.AddProcess(g, "sink", 1, func(int) error { return nil })
+	_ = pl.AddProcess(g, "a", 1, func(int) error { return nil })
+
+	pl.Connect(aOut, bIn)
+	pl.Connect(bOut, aIn)
+	pl.Connect(src, aIn)
+	pl.Connect(src, bIn) // output can't be connected twice
+
+	err := g.Validate()
+	assert.ErrorIs(t, err, pl.ErrInvalidGraph)
+
+	var msgs []string
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		msgs = append(msgs, err.Error())
+	}
+	assert.ElementsMatch(t, []string{
+		`invalid graph: duplicate stage name "a"`,
+		`invalid graph: output of "src" is already connected to "a"`,
+		`invalid graph: input of "sink" is not connected`,
+		`invalid graph: input of "a" is not connected`,
+		`invalid graph: cycle a -> b -> a`,
+	}, msgs)
+
+	// nothing is started
+	assert.True(t, errors.Is(g.Run(context.Background()), pl.ErrInvalidGraph))
+}
@@ -0,0 +1,410 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pair of items produced by `Zip` and `JoinByKey`
+type Pair[A any, B any] struct {
+	First  A
+	Second B
+}
+
+// items of `JoinByKey` inputs that were not paired when the inputs were closed,
+// in the order they were read
+type Unmatched[A any, B any] struct {
+	First  []A
+	Second []B
+}
+
+// unmatched item of `JoinByKey` waits for its pair at most `d`, then it's dropped;
+// dropped items are lost: they are not written to the unmatched items or reported
+func JoinWithin(d time.Duration) Option {
+	if d <= 0 {
+		panic("join duration must be positive")
+	}
+
+	return func(cfg *stageConfig) {
+		cfg.join.within = d
+	}
+}
+
+// `JoinByKey` buffers at most `n` unmatched items of both inputs, the oldest one is dropped
+// when the buffer is full; dropped items are lost: they are not written to the unmatched items or reported
+func JoinLimit(n int) Option {
+	if n <= 0 {
+		panic("join limit must be positive")
+	}
+
+	return func(cfg *stageConfig) {
+		cfg.join.limit = n
+	}
+}
+
+type joinConfig struct {
+	within time.Duration // 0 if items wait until the inputs are closed
+	limit  int           // 0 if buffer is not limited
+}
+
+// pair items of `a` and `b` by position
+//
+// output is closed when any input is closed, the rest of the other input is read and dropped,
+// so its writer is not blocked; output is left open on cancellation;
+// watermark of the output is the minimum of input watermarks
+func Zip[A any, B any](ctx context.Context, a <-chan A, b <-chan B, opts ...Option) <-chan Pair[A, B] {
+	st := newStage(ctx, "Zip", opts)
+	inputA := newInput(st, a)
+	inputB := newInput(st, b)
+	out := newOutput[Pair[A, B]](st)
+
+	spawn(ctx, st.origin(), func() {
+		defer st.end()
+
+		for {
+			itA, okA := inputA.read(ctx)
+			if !okA {
+				if ctx.Err() == nil {
+					out.close()
+					discard(ctx, inputB)
+				}
+				return
+			}
+
+			itB, okB := inputB.read(ctx)
+			if !okB {
+				if ctx.Err() == nil {
+					out.close()
+					discard(ctx, inputA)
+				}
+				return
+			}
+
+			it := item[Pair[A, B]]{
+				val:  Pair[A, B]{itA.val, itB.val},
+				span: itA.span,
+				wm:   minWatermark([]time.Time{itA.wm, itB.wm}, []int{1, 1}),
+			}
+			if !out.write(ctx, it) {
+				return
+			}
+		}
+	})
+
+	return out.ch
+}
+
+// read `in` until it's closed or the pipeline is cancelled
+func discard[T any](ctx context.Context, in *input[T]) {
+	for {
+		if _, ok := in.read(ctx); !ok {
+			return
+		}
+	}
+}
+
+// pair items of `a` and `b` that have the same key, items can arrive in any order
+//
+// each item is paired once, items with the same key are paired in the order they were read;
+// unmatched items are buffered until their pair arrives, the buffer can be bounded
+// by `JoinWithin` and `JoinLimit`, items dropped by them are lost;
+// items that are still unmatched when both inputs are closed are written to the returned oneshot,
+// nothing is written and output is left open on cancellation
+//
+// if `keyA` or `keyB` panics, the item is skipped and the panic is passed to the pipeline handler
+func JoinByKey[A any, B any, K comparable](ctx context.Context, a <-chan A, b <-chan B, keyA func(A) K, keyB func(B) K, opts ...Option) (<-chan Pair[A, B], Oneshot[Unmatched[A, B]]) {
+	st := newStage(ctx, "JoinByKey", opts)
+	inputA := newInput(st, a)
+	inputB := newInput(st, b)
+	out := newOutput[Pair[A, B]](st)
+	leftovers := NewOneshot[Unmatched[A, B]]()
+
+	// inputs are read concurrently, so the stage is not blocked by the input that is idle;
+	// readers are stopped when the stage exits, so they are not blocked on `itemsA` and `itemsB`
+	readCtx, stopReaders := context.WithCancel(ctx)
+	itemsA := make(chan item[A])
+	itemsB := make(chan item[B])
+	var readers sync.WaitGroup
+	readers.Add(2)
+	forward(ctx, readCtx, st, &readers, inputA, itemsA)
+	forward(ctx, readCtx, st, &readers, inputB, itemsB)
+
+	spawn(ctx, st.origin(), func() {
+		defer st.end()
+		defer readers.Wait()
+		defer stopReaders()
+
+		buf := newJoinBuffer[A, B, K]()
+		within, limit := st.cfg.join.within, st.cfg.join.limit
+		wms := make([]time.Time, 2) // watermark of each input
+		pending := []int{1, 1}      // inputs that are not closed
+
+		var timer Timer // triggered when the oldest unmatched item expires
+		var timerEnd time.Time
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+
+		for pending[0]+pending[1] > 0 {
+			var expired <-chan time.Time
+			if timer != nil {
+				expired = timer.C()
+			}
+
+			var (
+				itA          item[A]
+				itB          item[B]
+				readA, readB bool
+			)
+
+			select {
+			case itA, readA = <-itemsA:
+				if !readA {
+					pending[0] = 0
+					itemsA = nil
+				} else if itA.wm.After(wms[0]) {
+					wms[0] = itA.wm
+				}
+
+			case itB, readB = <-itemsB:
+				if !readB {
+					pending[1] = 0
+					itemsB = nil
+				} else if itB.wm.After(wms[1]) {
+					wms[1] = itB.wm
+				}
+
+			case <-expired:
+				timer = nil
+
+			case <-ctx.Done():
+				return
+			}
+
+			// item can't be paired with the expired ones, even if timer is not triggered yet
+			now := st.clock.Now()
+			if within > 0 {
+				buf.expire(now.Add(-within))
+			}
+
+			var (
+				it    item[Pair[A, B]]
+				found bool
+			)
+			if readA {
+				if key, ok := joinKey(ctx, st, itA, keyA); ok {
+					it.val, found = buf.addFirst(key, itA.val, now)
+					it.span = itA.span
+				}
+			} else if readB {
+				if key, ok := joinKey(ctx, st, itB, keyB); ok {
+					it.val, found = buf.addSecond(key, itB.val, now)
+					it.span = itB.span
+				}
+			}
+
+			if found {
+				it.wm = minWatermark(wms, pending)
+				if !out.write(ctx, it) {
+					return
+				}
+			}
+
+			if limit > 0 {
+				buf.limit(limit)
+			}
+
+			oldest, hasOldest := buf.oldest()
+			end := oldest.Add(within)
+			if timer != nil && (!hasOldest || !end.Equal(timerEnd)) {
+				timer.Stop()
+				timer = nil
+			}
+			if timer == nil && hasOldest && within > 0 {
+				timer = st.clock.NewTimer(end.Sub(now))
+				timerEnd = end
+			}
+		}
+
+		out.close()
+		leftovers.Write(buf.unmatched())
+	})
+
+	return out.ch, leftovers.Chan()
+}
+
+// key of the joined item, `ok` is false if `key` panicked, the item is skipped then
+func joinKey[T any, K comparable](ctx context.Context, st *stage, it item[T], key func(T) K) (k K, ok bool) {
+	err := st.call(it.span, func() error {
+		k = key(it.val)
+		return nil
+	})
+	if err != nil {
+		st.fail()
+		reportItemPanic(ctx, it.val, err)
+		return k, false
+	}
+	return k, true
+}
+
+// read items of `in` to `ch` until `in` is closed, `ch` is closed then;
+// reader stops without closing `ch` when `readCtx` is cancelled
+func forward[T any](ctx context.Context, readCtx context.Context, st *stage, readers *sync.WaitGroup, in *input[T], ch chan<- item[T]) {
+	spawn(ctx, st.origin(), func() {
+		defer readers.Done()
+
+		for {
+			it, ok := in.read(readCtx)
+			if !ok {
+				if readCtx.Err() == nil {
+					close(ch)
+				}
+				return
+			}
+
+			if !Write(readCtx, ch, it) {
+				return
+			}
+		}
+	})
+}
+
+// unmatched items of `JoinByKey`
+type joinBuffer[A any, B any, K comparable] struct {
+	first  map[K][]*joinEntry[A, B, K] // unmatched items of each key, in the order they were read
+	second map[K][]*joinEntry[A, B, K]
+	order  []*joinEntry[A, B, K] // all items in the order they were read, including matched ones
+	live   int                   // number of unmatched items in `order`
+}
+
+type joinEntry[A any, B any, K comparable] struct {
+	key     K
+	at      time.Time // when item was read
+	isFirst bool
+	first   A
+	second  B
+	done    bool // item is matched or dropped
+}
+
+func newJoinBuffer[A any, B any, K comparable]() *joinBuffer[A, B, K] {
+	return &joinBuffer[A, B, K]{
+		first:  make(map[K][]*joinEntry[A, B, K]),
+		second: make(map[K][]*joinEntry[A, B, K]),
+	}
+}
+
+// pair item of the first input with the oldest unmatched item of the second one, or buffer it
+func (buf *joinBuffer[A, B, K]) addFirst(key K, v A, now time.Time) (Pair[A, B], bool) {
+	if e := buf.take(buf.second, key); e != nil {
+		return Pair[A, B]{v, e.second}, true
+	}
+
+	buf.push(buf.first, &joinEntry[A, B, K]{key: key, at: now, isFirst: true, first: v})
+	return Pair[A, B]{}, false
+}
+
+// pair item of the second input with the oldest unmatched item of the first one, or buffer it
+func (buf *joinBuffer[A, B, K]) addSecond(key K, v B, now time.Time) (Pair[A, B], bool) {
+	if e := buf.take(buf.first, key); e != nil {
+		return Pair[A, B]{e.first, v}, true
+	}
+
+	buf.push(buf.second, &joinEntry[A, B, K]{key: key, at: now, second: v})
+	return Pair[A, B]{}, false
+}
+
+func (buf *joinBuffer[A, B, K]) push(queues map[K][]*joinEntry[A, B, K], e *joinEntry[A, B, K]) {
+	queues[e.key] = append(queues[e.key], e)
+	buf.order = append(buf.order, e)
+	buf.live += 1
+}
+
+// remove the oldest unmatched item with `key`, nil if there is none
+func (buf *joinBuffer[A, B, K]) take(queues map[K][]*joinEntry[A, B, K], key K) *joinEntry[A, B, K] {
+	q := queues[key]
+	if len(q) == 0 {
+		return nil
+	}
+
+	e := q[0]
+	if len(q) == 1 {
+		delete(queues, key) // don't keep keys that are not used anymore
+	} else {
+		q[0] = nil
+		queues[key] = q[1:]
+	}
+
+	e.done = true
+	buf.live -= 1
+	buf.compact()
+	return e
+}
+
+// drop the oldest unmatched item
+func (buf *joinBuffer[A, B, K]) dropOldest() {
+	e := buf.order[0] // `order` starts with an unmatched item after `compact`
+	if e.isFirst {
+		buf.take(buf.first, e.key)
+	} else {
+		buf.take(buf.second, e.key)
+	}
+}
+
+// drop the oldest items to keep at most `n` unmatched items
+func (buf *joinBuffer[A, B, K]) limit(n int) {
+	for buf.live > n {
+		buf.dropOldest()
+	}
+}
+
+// drop items that were read by `deadline`
+func (buf *joinBuffer[A, B, K]) expire(deadline time.Time) {
+	for buf.live > 0 && !buf.order[0].at.After(deadline) {
+		buf.dropOldest()
+	}
+}
+
+// read time of the oldest unmatched item
+func (buf *joinBuffer[A, B, K]) oldest() (time.Time, bool) {
+	if buf.live == 0 {
+		return time.Time{}, false
+	}
+	return buf.order[0].at, true
+}
+
+// remove matched items from `order`, items of the same key are matched in order,
+// but items of different keys are not, so `order` can have gaps
+func (buf *joinBuffer[A, B, K]) compact() {
+	n := 0
+	for n < len(buf.order) && buf.order[n].done {
+		buf.order[n] = nil
+		n += 1
+	}
+	buf.order = buf.order[n:]
+
+	if len(buf.order) > 2*buf.live+16 {
+		live := make([]*joinEntry[A, B, K], 0, buf.live)
+		for _, e := range buf.order {
+			if !e.done {
+				live = append(live, e)
+			}
+		}
+		buf.order = live
+	}
+}
+
+func (buf *joinBuffer[A, B, K]) unmatched() (res Unmatched[A, B]) {
+	for _, e := range buf.order {
+		switch {
+		case e.done:
+		case e.isFirst:
+			res.First = append(res.First, e.first)
+		default:
+			res.Second = append(res.Second, e.second)
+		}
+	}
+	return
+}
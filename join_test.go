@@ -0,0 +1,184 @@
+package pipeline_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZip(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	names := pl.Transform(ctx, 1, sequence(ctx, 0, 3), strconv.Itoa)
+	res := pl.Zip(ctx, sequence(ctx, 10, 15), names)
+
+	// the rest of the longer input is dropped
+	assert.Equal(t, []pl.Pair[int, string]{{10, "0"}, {11, "1"}, {12, "2"}}, pipelinetest.ReadAll(t, res))
+}
+
+func TestZip_Cancel(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	res := pl.Zip(ctx, sequence(ctx, 0, 10), make(chan int))
+	pipelinetest.CheckShutdown(t, cancel)
+
+	// output is left open on cancel
+	pipelinetest.CheckPending(t, res)
+}
+
+type order struct {
+	id   int
+	item string
+}
+
+type payment struct {
+	order  int
+	amount int
+}
+
+func orderID(o order) int {
+	return o.id
+}
+
+func paymentOrder(p payment) int {
+	return p.order
+}
+
+func TestJoinByKey(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	orders := make(chan order)
+	payments := make(chan payment)
+	res, leftovers := pl.JoinByKey(ctx, orders, payments, orderID, paymentOrder)
+
+	orders <- order{1, "book"}
+	orders <- order{2, "pen"}
+	pipelinetest.CheckPending(t, res)
+
+	payments <- payment{2, 5}
+	assert.Equal(t, pl.Pair[order, payment]{order{2, "pen"}, payment{2, 5}}, pipelinetest.CheckRead(t, res))
+
+	// payment can arrive first
+	payments <- payment{3, 7}
+	payments <- payment{3, 8}
+	orders <- order{3, "cup"}
+	assert.Equal(t, pl.Pair[order, payment]{order{3, "cup"}, payment{3, 7}}, pipelinetest.CheckRead(t, res))
+
+	close(orders)
+	pipelinetest.CheckPending(t, leftovers)
+
+	close(payments)
+	pipelinetest.ReadAll(t, res) // closed
+
+	assert.Equal(t, pl.Unmatched[order, payment]{
+		First:  []order{{1, "book"}},
+		Second: []payment{{3, 8}},
+	}, pipelinetest.CheckRead(t, leftovers))
+}
+
+func TestJoinByKey_Limit(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	orders := make(chan order)
+	payments := make(chan payment)
+	res, leftovers := pl.JoinByKey(ctx, orders, payments, orderID, paymentOrder, pl.JoinLimit(2))
+
+	orders <- order{1, "book"}
+	orders <- order{2, "pen"}
+	orders <- order{3, "cup"} // the oldest order is dropped
+
+	close(orders)
+	close(payments)
+	pipelinetest.ReadAll(t, res) // closed
+
+	assert.Equal(t, pl.Unmatched[order, payment]{
+		First: []order{{2, "pen"}, {3, "cup"}},
+	}, pipelinetest.CheckRead(t, leftovers))
+}
+
+func TestJoinByKey_Within(t *testing.T) {
+	clock := pipelinetest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	ctx, cancel := pl.NewPipeline(pl.WithClock(context.Background(), clock))
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	orders := make(chan order)
+	payments := make(chan payment)
+	res, leftovers := pl.JoinByKey(ctx, orders, payments, orderID, paymentOrder, pl.JoinWithin(time.Minute))
+
+	orders <- order{1, "book"}
+	clock.BlockUntilTimers(1)
+	clock.Advance(30 * time.Second)
+
+	orders <- order{2, "pen"}
+	clock.Advance(30 * time.Second) // the first order expires
+
+	payments <- payment{1, 10}
+	payments <- payment{2, 5}
+	assert.Equal(t, pl.Pair[order, payment]{order{2, "pen"}, payment{2, 5}}, pipelinetest.CheckRead(t, res))
+
+	close(orders)
+	close(payments)
+	pipelinetest.ReadAll(t, res) // closed
+
+	assert.Equal(t, pl.Unmatched[order, payment]{
+		Second: []payment{{1, 10}},
+	}, pipelinetest.CheckRead(t, leftovers))
+}
+
+func TestJoinByKey_Cancel(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	orders := make(chan order)
+	res, leftovers := pl.JoinByKey(ctx, orders, make(chan payment), orderID, paymentOrder)
+	orders <- order{1, "book"}
+
+	pipelinetest.CheckShutdown(t, cancel)
+
+	// leftovers are not written and output is left open on cancel
+	pipelinetest.CheckPending(t, res)
+	pipelinetest.CheckPending(t, leftovers)
+}
+
+func TestJoinByKey_KeyPanic(t *testing.T) {
+	ctx, cancel, p := newPanicPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	orders := make(chan order)
+	payments := make(chan payment)
+	res, leftovers := pl.JoinByKey(ctx, orders, payments, func(o order) int {
+		if o.id == 0 {
+			panic("bad order")
+		}
+		return o.id
+	}, paymentOrder)
+
+	orders <- order{0, "bad"}
+	orders <- order{1, "book"}
+	payments <- payment{1, 5}
+	assert.Equal(t, pl.Pair[order, payment]{order{1, "book"}, payment{1, 5}}, pipelinetest.CheckRead(t, res))
+
+	close(orders)
+	close(payments)
+	pipelinetest.ReadAll(t, res) // closed
+
+	// the item is skipped
+	assert.Equal(t, pl.Unmatched[order, payment]{}, pipelinetest.CheckRead(t, leftovers))
+
+	errs := p.Get()
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, order{0, "bad"}, errs[0].Item)
+	}
+}
+
+func TestJoin_InvalidOptions(t *testing.T) {
+	assert.Panics(t, func() { pl.JoinWithin(0) })
+	assert.Panics(t, func() { pl.JoinLimit(0) })
+}
@@ -0,0 +1,143 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stage outputs of a pipeline that pass item metadata to downstream stages
+type linkRegistry struct {
+	links sync.Map // `<-chan T` of stage output -> `*link[T]`
+}
+
+// registry of the pipeline or of the tracer, nil if items don't need metadata
+func getLinks(ctx context.Context) *linkRegistry {
+	if reg, ok := ctx.Value(linksKey).(*linkRegistry); ok {
+		return reg
+	}
+
+	if tr := getTracing(ctx); tr != nil {
+		return &tr.links
+	}
+	return nil
+}
+
+// metadata of items written to a stage output channel, it's passed beside the channel
+// to the downstream stage once it's attached
+//
+// values are always written to the channel itself, so metadata doesn't change delivery:
+// order of a buffered channel is kept and other readers (e.g. `Read`) get every value;
+// metadata is matched to values by their order, so it's mixed up (but not leaked)
+// if the channel is also read by code other than pipeline stages
+type link[T any] struct {
+	watermarked bool        // items carry watermarks, see `AssignWatermarks`
+	attached    atomic.Bool // downstream stage reads the channel, so metadata is queued
+
+	writing chan struct{} // held while value is written, so metadata is queued in the order of values
+	reading chan struct{} // held while value is read, so it's matched with its metadata
+
+	mu      sync.Mutex
+	meta    []itemMeta // metadata of values that are written, but not read yet
+	limit   int        // max length of `meta`: values in the channel, one written and one read
+	written uint64     // number of values written to the channel
+	read    uint64     // number of values read by stages
+	first   uint64     // index of the first value written with metadata
+	tagged  bool       // values starting from `first` are written with metadata
+}
+
+type itemMeta struct {
+	span SpanID
+	wm   time.Time
+}
+
+func registerLink[T any](reg *linkRegistry, ch chan T, watermarked bool) *link[T] {
+	if reg == nil {
+		return nil
+	}
+
+	l := &link[T]{
+		watermarked: watermarked,
+		writing:     make(chan struct{}, 1),
+		reading:     make(chan struct{}, 1),
+		limit:       cap(ch) + 2,
+	}
+	reg.links.Store((<-chan T)(ch), l)
+	return l
+}
+
+func attachLink[T any](reg *linkRegistry, ch <-chan T) *link[T] {
+	if reg == nil {
+		return nil
+	}
+
+	v, ok := reg.links.Load(ch)
+	if !ok {
+		return nil // not a stage output or stage has already finished
+	}
+
+	l := v.(*link[T])
+	l.attached.Store(true)
+	return l
+}
+
+func unregisterLink[T any](reg *linkRegistry, ch chan T) {
+	reg.links.Delete((<-chan T)(ch))
+}
+
+// queue metadata of the value that is written next, writer must hold `writing`;
+// returns `false` if nothing was queued, because downstream stage is not attached yet
+func (l *link[T]) push(it item[T]) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.tagged {
+		if !l.attached.Load() {
+			return false
+		}
+
+		l.tagged = true
+		l.first = l.written
+	}
+
+	if len(l.meta) >= l.limit {
+		// values were read without their metadata, drop the stale one
+		l.meta[0] = itemMeta{}
+		l.meta = l.meta[1:]
+	}
+
+	l.meta = append(l.meta, itemMeta{span: it.span, wm: it.wm})
+	return true
+}
+
+// must be called after the value is written (or not) to the channel
+func (l *link[T]) pushed(written bool, queued bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case written:
+		l.written += 1
+	case queued && len(l.meta) > 0:
+		l.meta = l.meta[:len(l.meta)-1]
+	}
+}
+
+// match the value that was just read with its metadata, reader must hold `reading`
+func (l *link[T]) pop(v T) item[T] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	idx := l.read
+	l.read += 1
+
+	if !l.tagged || idx < l.first || len(l.meta) == 0 {
+		return item[T]{val: v} // written before downstream was attached
+	}
+
+	m := l.meta[0]
+	l.meta[0] = itemMeta{}
+	l.meta = l.meta[1:]
+	return item[T]{val: v, span: m.span, wm: m.wm}
+}
@@ -1,8 +1,9 @@
 package pipeline
 
 // oneshot channel, 1-item buffered in most cases
-// this channel is never closed, use `Oneshot::Read` or `WaitFirst`
-// to read a value in non-stuck manner
+// this channel is never closed, except for results of non-fallible functions
+// that are closed without a value if their callback panics (e.g. `Collect`);
+// use `Read`, `ReadErr` or `WaitFirst` to read a value in non-stuck manner
 type Oneshot[T any] <-chan T
 
 // writer side version that supports writing
@@ -24,6 +25,16 @@ func (m OneshotMut[T]) Chan() Oneshot[T] {
 	return m.ch
 }
 
+// close without a value, readers receive `ok = false`
+func (m OneshotMut[T]) close() {
+	close(m.ch)
+}
+
+// write unless the channel is full, returns `false` then
+func (m OneshotMut[T]) tryWrite(val T) bool {
+	return trySend(m.ch, val)
+}
+
 func (m OneshotMut[T]) Write(val T) {
 	select {
 	case m.ch <- val:
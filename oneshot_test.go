@@ -4,17 +4,18 @@ import (
 	"testing"
 
 	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestOneshot(t *testing.T) {
 	ch := pl.NewOneshot[int]()
-	checkPending(t, ch.Chan())
+	pipelinetest.CheckPending(t, ch.Chan())
 
-	withTimeout(t, "writing to oneshot channel", func() {
+	pipelinetest.WithTimeout(t, "writing to oneshot channel", func() {
 		ch.Write(42) // it must not block
 	})
 
-	r := checkRead(t, ch.Chan())
+	r := pipelinetest.CheckRead(t, ch.Chan())
 	assert.Equal(t, r, 42)
 }
@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// stage option, it can be passed to stage functions (`Transform`, `Generate`, etc.)
+// or set for all stages created with a context by `WithOptions`
+type Option func(*stageConfig)
+
+type stageConfig struct {
+	name      string
+	buffer    int
+	pool      *WorkerPool
+	autoscale *autoscaleConfig
+	limiter   typedOpt // `limiter[T]`, see `RateLimit` and `RateLimitByKey`
+	retry     *RetryPolicy
+	errors    errorConfig
+	tee       []TeePolicy
+	join      joinConfig
+	eventTime typedOpt // `func(T) time.Time`, see `EventTime`
+	late      typedOpt // `chan<- T`, see `LateItems`
+
+	inContext bool // options of `WithOptions` are applied now
+}
+
+// option value that depends on the stage item type (e.g. key function of `RateLimitByKey`),
+// see `typedOption`
+type typedOpt struct {
+	name        string // option name for error message
+	v           any
+	fromContext bool // set by `WithOptions`
+}
+
+func (cfg *stageConfig) typed(name string, v any) typedOpt {
+	return typedOpt{name: name, v: v, fromContext: cfg.inContext}
+}
+
+// value of typed option for the stage, `ok` is false if it's not set
+//
+// stage panics on creation if option that was passed to it directly doesn't match its type;
+// options of `WithOptions` are applied to stages of all types, so they are ignored then
+func typedOption[V any](st *stage, opt typedOpt) (v V, ok bool) {
+	if opt.v == nil {
+		return v, false
+	}
+
+	if v, ok = opt.v.(V); !ok && !opt.fromContext {
+		panic(fmt.Sprintf("%s option type %T doesn't match %s stage", opt.name, opt.v, st.api))
+	}
+	return
+}
+
+// set stage name reported by `Stats` and tracer
+func Name(name string) Option {
+	return func(cfg *stageConfig) {
+		cfg.name = name
+	}
+}
+
+// set buffer size of stage output channel
+func Buffer(size int) Option {
+	return func(cfg *stageConfig) {
+		cfg.buffer = max(size, 0)
+	}
+}
+
+// apply options to all stages created with returned context,
+// options passed to a stage function directly take precedence
+//
+// the options are applied to every stage created with the returned context and with contexts
+// derived from it, so prefer passing options directly; if name is set this way,
+// stages get unique names with a number suffix (e.g. "parse#2"); stages that library
+// creates internally (e.g. to merge graph inputs) don't use these options
+func WithOptions(ctx context.Context, opts ...Option) context.Context {
+	prev, _ := ctx.Value(optionsKey).([]Option)
+	return context.WithValue(ctx, optionsKey, append(slices.Clip(prev), opts...))
+}
+
+// context for stages created internally by the library, so user options don't apply to them
+func withoutOptions(ctx context.Context) context.Context {
+	if ctx.Value(optionsKey) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, optionsKey, []Option(nil))
+}
+
+func newStageConfig(ctx context.Context, opts []Option) (cfg stageConfig) {
+	ctxOpts, _ := ctx.Value(optionsKey).([]Option)
+	cfg.inContext = true
+	for _, opt := range ctxOpts {
+		opt(&cfg)
+	}
+	cfg.inContext = false
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return
+}
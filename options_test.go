@@ -0,0 +1,104 @@
+package pipeline_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptions_GenerateBuffer(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	written := pl.NewSignal()
+	seq := pl.Generate(ctx, func(w pl.Writer[int]) {
+		for k := range 5 {
+			if !w.Write(k) {
+				return
+			}
+		}
+		written.Set()
+	}, pl.Buffer(5))
+
+	// nobody reads, but all values fit the buffer
+	pipelinetest.CheckSignaled(t, written)
+	assert.Equal(t, 5, cap(seq))
+
+	pipelinetest.WithTimeout(t, "read buffered", func() {
+		var vals []int
+		for v := range seq {
+			vals = append(vals, v)
+		}
+		assert.Equal(t, []int{0, 1, 2, 3, 4}, vals)
+	})
+}
+
+func TestOptions_TransformBuffer(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 10)
+
+	processed := atomic.Int32{}
+	res := pl.Transform(ctx, 1, seq, func(x int) int {
+		processed.Add(1)
+		return x
+	}, pl.Buffer(3))
+
+	pipelinetest.WithTimeout(t, "fill buffer", func() {
+		// 3 items in buffer and one is waiting in worker
+		for processed.Load() != 4 {
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	assert.Equal(t, 3, len(res))
+
+	_, cherr := pl.TransformErr(ctx, 1, seq, func(x int) (int, error) {
+		return x, nil
+	}, pl.Buffer(7))
+	pipelinetest.CheckPending(t, cherr)
+}
+
+func TestOptions_FanInBuffer(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	a := sequence(ctx, 0, 2)
+	b := sequence(ctx, 10, 2)
+
+	res, _ := pl.FanInWith(ctx, []<-chan int{a, b}, pl.Buffer(4))
+	assert.Equal(t, 4, cap(res))
+
+	pipelinetest.WithTimeout(t, "fill buffer", func() {
+		for len(res) != 4 {
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
+
+func TestOptions_Name(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	named := pl.WithOptions(ctx, pl.Name("from-context"))
+
+	seq := pl.Generate(named, func(w pl.Writer[int]) {}, pl.Name("numbers"))
+	_ = pl.Process(named, 1, seq, func(int) {})
+	_, _ = pl.FanIn[int](named)
+	_, _ = pl.MergeSortedWith(ctx, intLess, nil, pl.Name("merge"))
+
+	stats := pl.Stats(ctx)
+	if assert.Len(t, stats, 4) {
+		assert.Equal(t, "numbers", stats[0].Name) // direct option takes precedence
+		assert.Equal(t, "from-context", stats[1].Name)
+		assert.Equal(t, "from-context#2", stats[2].Name) // names are unique
+		assert.Equal(t, "FanIn", stats[2].API)
+		assert.Equal(t, "merge", stats[3].Name)
+	}
+}
@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+// panic recovered from a callback passed to one of pipeline functions
+//
+// fallible functions (`GoErr`, `TransformErr`, etc.) send it to their error channel,
+// other functions pass it to the handler set by `WithPanicHandler`, so items skipped
+// by them (e.g. by `Transform`) can be seen there
+type PanicError struct {
+	API   string // pipeline function that spawned the goroutine, e.g. "Transform"
+	Value any    // value passed to `panic`
+	Stack []byte // stack trace of the panicked goroutine
+	Item  any    // item that was skipped due to panic, nil if callback doesn't process items
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in %s: %v", e.API, e.Value)
+}
+
+// allow `errors.Is/As` to check the value if it was an error
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// set a handler for panics in non-fallible functions (`Go`, `Transform`, etc.)
+//
+// the handler can be called concurrently from multiple goroutines,
+// panics are logged by default
+func WithPanicHandler(ctx context.Context, handler func(*PanicError)) context.Context {
+	return context.WithValue(ctx, panicHandlerKey, handler)
+}
+
+// `err` must be `*PanicError`, that is the only way non-fallible callbacks can fail
+func reportPanic(ctx context.Context, err error) {
+	perr := err.(*PanicError)
+
+	handler, _ := ctx.Value(panicHandlerKey).(func(*PanicError))
+	if handler == nil {
+		log.Printf("pipeline: %v\n%s", perr, perr.Stack)
+		return
+	}
+
+	handler(perr)
+}
+
+// report panic of a callback called for item `v`, the item is skipped
+func reportItemPanic(ctx context.Context, v any, err error) {
+	err.(*PanicError).Item = v
+	reportPanic(ctx, err)
+}
+
+// call `cb` and convert its panic to `PanicError`
+func catchPanic(api string, cb func()) (perr *PanicError) {
+	defer func() {
+		if r := recover(); r != nil {
+			perr = &PanicError{
+				API:   api,
+				Value: r,
+				Stack: debug.Stack(),
+			}
+		}
+	}()
+
+	cb()
+	return nil
+}
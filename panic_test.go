@@ -0,0 +1,268 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// collect panics reported to the pipeline handler
+type panics struct {
+	mu   sync.Mutex
+	errs []*pl.PanicError
+}
+
+func (p *panics) Handle(perr *pl.PanicError) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.errs = append(p.errs, perr)
+}
+
+func (p *panics) Get() []*pl.PanicError {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*pl.PanicError(nil), p.errs...)
+}
+
+func newPanicPipeline() (context.Context, context.CancelFunc, *panics) {
+	p := &panics{}
+	ctx, cancel := pl.NewPipeline(pl.WithPanicHandler(context.Background(), p.Handle))
+	return ctx, cancel, p
+}
+
+func TestPanicError(t *testing.T) {
+	perr := &pl.PanicError{API: "Go", Value: errTest}
+	assert.Equal(t, "panic in Go: test", perr.Error())
+	assert.ErrorIs(t, perr, errTest)
+
+	perr = &pl.PanicError{API: "Go", Value: 42}
+	assert.Nil(t, errors.Unwrap(perr))
+}
+
+func TestPanic_Go(t *testing.T) {
+	ctx, cancel, p := newPanicPipeline()
+
+	finished := pl.NewSignal()
+	pl.Go(ctx, func() {
+		defer finished.Set()
+		panic("boom")
+	})
+
+	pipelinetest.CheckSignaled(t, finished)
+	pipelinetest.CheckShutdown(t, cancel)
+
+	errs := p.Get()
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "Go", errs[0].API)
+		assert.Equal(t, "boom", errs[0].Value)
+		assert.Contains(t, string(errs[0].Stack), "panic_test.go")
+	}
+}
+
+func TestPanic_GoErr(t *testing.T) {
+	ctx, cancel, p := newPanicPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	cherr := pl.GoErr(ctx, func() error {
+		panic(errTest)
+	})
+
+	err := pipelinetest.CheckRead(t, cherr)
+
+	var perr *pl.PanicError
+	if assert.ErrorAs(t, err, &perr) {
+		assert.Equal(t, "GoErr", perr.API)
+	}
+	assert.ErrorIs(t, err, errTest)
+
+	assert.Empty(t, p.Get()) // fallible functions don't call handler
+}
+
+func TestPanic_Run(t *testing.T) {
+	ctx, cancel, p := newPanicPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	finished := pl.Run(ctx, func() {
+		panic("boom")
+	})
+	pipelinetest.CheckSignaled(t, finished)
+
+	finished, cherr := pl.RunErr(ctx, func() error {
+		panic("boom")
+	})
+
+	err := pipelinetest.CheckRead(t, cherr)
+	assert.ErrorContains(t, err, "panic in RunErr: boom")
+	pipelinetest.CheckPending(t, finished)
+
+	errs := p.Get()
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "Run", errs[0].API)
+	}
+}
+
+func TestPanic_Collect(t *testing.T) {
+	ctx, cancel, p := newPanicPipeline()
+
+	res := pl.Collect(ctx, func() int {
+		panic("boom")
+	})
+
+	assert.Empty(t, pipelinetest.ReadAll(t, res)) // closed without a value
+	pipelinetest.CheckShutdown(t, cancel)
+
+	errs := p.Get()
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "Collect", errs[0].API)
+	}
+}
+
+func TestPanic_CollectErr(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	res, cherr := pl.CollectErr(ctx, func() (int, error) {
+		panic("boom")
+	})
+
+	err := pipelinetest.CheckRead(t, cherr)
+	assert.ErrorContains(t, err, "panic in CollectErr: boom")
+	pipelinetest.CheckPending(t, res)
+}
+
+func TestPanic_Generate(t *testing.T) {
+	ctx, cancel, p := newPanicPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := pl.Generate(ctx, func(w pl.Writer[int]) {
+		_ = w.Write(1)
+		panic("boom")
+	})
+
+	pipelinetest.WithTimeout(t, "read generated", func() {
+		var vals []int
+		for v := range seq {
+			vals = append(vals, v)
+		}
+		assert.Equal(t, []int{1}, vals) // channel is closed after panic
+	})
+
+	errs := p.Get()
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "Generate", errs[0].API)
+	}
+}
+
+func TestPanic_GenerateErr(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	_, cherr := pl.GenerateErr(ctx, func(w pl.Writer[int]) error {
+		panic("boom")
+	})
+
+	err := pipelinetest.CheckRead(t, cherr)
+	assert.ErrorContains(t, err, "panic in GenerateErr: boom")
+}
+
+func TestPanic_TransformSkipsItem(t *testing.T) {
+	ctx, cancel, p := newPanicPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 10)
+	res := pl.Transform(ctx, 1, seq, func(x int) int {
+		if x == 5 {
+			panic("bad record")
+		}
+		return x
+	})
+
+	pipelinetest.WithTimeout(t, "read transformed", func() {
+		sum := 0
+		for v := range res {
+			sum += v
+		}
+		assert.Equal(t, 40, sum) // 45 without 5
+	})
+
+	errs := p.Get()
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "Transform", errs[0].API)
+		assert.Equal(t, "bad record", errs[0].Value)
+		assert.Equal(t, 5, errs[0].Item) // skipped item
+	}
+}
+
+func TestPanic_TransformErr(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 10)
+	_, cherr := pl.TransformErr(ctx, 2, seq, func(x int) (int, error) {
+		panic("boom")
+	})
+
+	err := pipelinetest.CheckRead(t, cherr)
+	assert.ErrorContains(t, err, "panic in TransformErr: boom")
+}
+
+func TestPanic_TransformOrderedSkipsItem(t *testing.T) {
+	ctx, cancel, p := newPanicPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 5)
+	res := pl.TransformOrdered(ctx, 2, 2, seq, func(x int) int {
+		if x == 2 {
+			panic("bad record")
+		}
+		return x
+	})
+
+	pipelinetest.WithTimeout(t, "read transformed", func() {
+		var vals []int
+		for v := range res {
+			vals = append(vals, v)
+		}
+		assert.Equal(t, []int{0, 1, 3, 4}, vals)
+	})
+
+	assert.Len(t, p.Get(), 1)
+}
+
+func TestPanic_Process(t *testing.T) {
+	ctx, cancel, p := newPanicPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	sum := adder{}
+
+	seq := sequence(ctx, 0, 10)
+	finished := pl.Process(ctx, 2, seq, func(x int) {
+		if x%2 == 1 {
+			panic("odd")
+		}
+		sum.Add(x)
+	})
+
+	pipelinetest.CheckSignaled(t, finished)
+	assert.Equal(t, 20, sum.Value())
+	assert.Len(t, p.Get(), 5)
+}
+
+func TestPanic_ProcessErr(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 10)
+	finished, cherr := pl.ProcessErr(ctx, 2, seq, func(x int) error {
+		panic("boom")
+	})
+
+	err := pipelinetest.CheckRead(t, cherr)
+	assert.ErrorContains(t, err, "panic in ProcessErr: boom")
+	pipelinetest.CheckPending(t, finished)
+}
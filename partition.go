@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"hash/maphash"
+	"math"
+	"reflect"
+)
+
+// split `in` into `n` channels, each item is routed by a stable hash of `keyFn(item)`,
+// so items with the same key always go to the same channel, see `HashPartition`
+//
+// items are written one by one, so a slow consumer blocks all others;
+// all outputs are closed when `in` is closed, if `keyFn` panics, the panic is sent
+// to the error channel and all outputs are closed too;
+// cancellation cause is sent to the error channel and outputs are left open
+func Partition[T any, K comparable](ctx context.Context, in <-chan T, n int, keyFn func(T) K, opts ...Option) ([]<-chan T, Oneshot[error]) {
+	return partitionStage(ctx, "Partition", in, n, keyFn, HashPartition[K], opts)
+}
+
+// same as `Partition`, but items are routed to `partition(key, n)` instead of the key hash
+//
+// if `partition` returns index out of `[0, n)`, the error is sent
+// to the error channel and all outputs are closed
+func PartitionBy[T any, K comparable](ctx context.Context, in <-chan T, n int, keyFn func(T) K, partition func(key K, n int) int, opts ...Option) ([]<-chan T, Oneshot[error]) {
+	return partitionStage(ctx, "PartitionBy", in, n, keyFn, partition, opts)
+}
+
+func partitionStage[T any, K comparable](ctx context.Context, api string, in <-chan T, n int, keyFn func(T) K, partition func(K, int) int, opts []Option) ([]<-chan T, Oneshot[error]) {
+	if n <= 0 {
+		panic("number of partitions must be positive")
+	}
+
+	st := newStage(ctx, api, opts)
+	input := newInput(st, in)
+	cherr := NewOneshot[error]()
+
+	outs := make([]*output[T], n)
+	res := make([]<-chan T, n)
+	for k := range outs {
+		outs[k] = newOutput[T](st)
+		res[k] = outs[k].ch
+	}
+
+	spawn(ctx, st.origin(), func() {
+		defer st.end()
+
+		for {
+			it, ok := input.read(ctx)
+			if !ok {
+				break
+			}
+
+			var idx int
+			err := st.call(it.span, func() error {
+				idx = partition(keyFn(it.val), n)
+				if idx < 0 || idx >= n {
+					return fmt.Errorf("partition index %d is out of range [0, %d)", idx, n)
+				}
+				return nil
+			})
+			if err != nil {
+				st.fail()
+				st.reportError(ctx, err)
+				cherr.Write(err)
+				break
+			}
+
+			if !outs[idx].write(ctx, it) {
+				break
+			}
+		}
+
+		if ctx.Err() != nil {
+			cherr.Write(context.Cause(ctx))
+			return
+		}
+
+		for _, out := range outs {
+			out.close()
+		}
+	})
+
+	return res, cherr.Chan()
+}
+
+// default `Partition` routing: FNV-1a hash of the key modulo `n`
+//
+// hash of strings, booleans and numbers (including named types like `type UserID string`)
+// doesn't change between runs; other keys (e.g. structs or pointers) are hashed by
+// `maphash.Comparable`, so their hash is stable only within the process,
+// use `PartitionBy` to route them across runs
+func HashPartition[K comparable](key K, n int) int {
+	return int(hashKey(key) % uint64(n))
+}
+
+func hashKey[K comparable](key K) uint64 {
+	h := fnv.New64a()
+
+	var buf [8]byte
+	putUint := func(v uint64) {
+		binary.LittleEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+
+	// switch by kind, so named types are hashed as their underlying types
+	switch v := reflect.ValueOf(key); v.Kind() {
+	case reflect.String:
+		h.Write([]byte(v.String()))
+	case reflect.Bool:
+		if v.Bool() {
+			putUint(1)
+		} else {
+			putUint(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		putUint(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		putUint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f == 0 {
+			f = 0 // -0 is equal to 0, so it must have the same hash
+		}
+		putUint(math.Float64bits(f))
+	default:
+		return maphash.Comparable(hashSeed, key)
+	}
+
+	return h.Sum64()
+}
+
+var hashSeed = maphash.MakeSeed()
@@ -0,0 +1,163 @@
+package pipeline_test
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func readPartitions[T any](t *testing.T, outs []<-chan T) [][]T {
+	t.Helper()
+
+	var wg sync.WaitGroup
+	res := make([][]T, len(outs))
+	for k, out := range outs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := range out {
+				res[k] = append(res[k], v)
+			}
+		}()
+	}
+
+	pipelinetest.WithTimeout(t, "read all partitions", wg.Wait)
+	return res
+}
+
+func TestPartition(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	outs, cherr := pl.Partition(ctx, sequence(ctx, 0, 100), 4, func(x int) int {
+		return x % 10
+	})
+	assert.Len(t, outs, 4)
+
+	res := readPartitions(t, outs)
+
+	total := 0
+	for k, part := range res {
+		total += len(part)
+		for idx, v := range part {
+			// items with the same key are in the same partition
+			assert.Equal(t, k, pl.HashPartition(v%10, 4))
+
+			// order is preserved
+			if idx > 0 {
+				assert.Less(t, part[idx-1], v)
+			}
+		}
+	}
+	assert.Equal(t, 100, total)
+
+	pipelinetest.CheckPending(t, cherr)
+}
+
+func TestPartitionBy(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	outs, _ := pl.PartitionBy(ctx, sequence(ctx, 0, 6), 2, func(x int) bool {
+		return x%2 == 0
+	}, func(even bool, n int) int {
+		if even {
+			return 0
+		}
+		return 1
+	})
+
+	assert.Equal(t, [][]int{{0, 2, 4}, {1, 3, 5}}, readPartitions(t, outs))
+}
+
+func TestPartitionBy_OutOfRange(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	outs, cherr := pl.PartitionBy(ctx, sequence(ctx, 0, 6), 2, func(x int) int {
+		return x
+	}, func(x int, n int) int {
+		return x
+	})
+
+	// items before the failed one are delivered, then outputs are closed
+	assert.Equal(t, [][]int{{0}, {1}}, readPartitions(t, outs))
+	assert.EqualError(t, pipelinetest.CheckRead(t, cherr), "partition index 2 is out of range [0, 2)")
+}
+
+func TestPartition_Panic(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	outs, cherr := pl.Partition(ctx, sequence(ctx, 0, 6), 2, func(x int) int {
+		if x == 3 {
+			panic("bad key")
+		}
+		return x
+	})
+
+	readPartitions(t, outs) // closed
+
+	var perr *pl.PanicError
+	assert.ErrorAs(t, pipelinetest.CheckRead(t, cherr), &perr)
+}
+
+func TestPartition_Cancel(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	outs, cherr := pl.Partition(ctx, sequence(ctx, 0, 100), 3, func(x int) int { return x })
+
+	// wait until the stage is blocked on write
+	pipelinetest.WithTimeout(t, "read one item", func() {
+		_, _ = pl.WaitFirst(ctx, outs...)
+	})
+
+	pipelinetest.CheckShutdown(t, cancel)
+
+	for _, out := range outs {
+		pipelinetest.CheckPending(t, out) // would not close
+	}
+	assert.ErrorIs(t, pipelinetest.CheckRead(t, cherr), context.Canceled)
+}
+
+func TestPartition_PropagateCause(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+
+	outs, cherr := pl.Partition(ctx, make(chan int), 2, func(x int) int { return x })
+	cancel(errTest)
+
+	assert.ErrorIs(t, pipelinetest.CheckRead(t, cherr), errTest)
+	pipelinetest.CheckPending(t, outs[0])
+}
+
+func TestHashPartition(t *testing.T) {
+	// hash doesn't depend on the process
+	assert.Equal(t, 4, pl.HashPartition("user-1", 8))
+	assert.Equal(t, 7, pl.HashPartition(42, 8))
+
+	// named types are hashed as their underlying types
+	type userID string
+	type shard int
+	assert.Equal(t, pl.HashPartition("user-1", 8), pl.HashPartition(userID("user-1"), 8))
+	assert.Equal(t, pl.HashPartition(42, 8), pl.HashPartition(shard(42), 8))
+	assert.Equal(t, pl.HashPartition(0.0, 8), pl.HashPartition(math.Copysign(0, -1), 8))
+
+	type key struct {
+		a int
+		b string
+	}
+	assert.Equal(t, pl.HashPartition(key{1, "x"}, 8), pl.HashPartition(key{1, "x"}, 8))
+
+	ptr := &key{1, "x"}
+	assert.Equal(t, pl.HashPartition(ptr, 8), pl.HashPartition(ptr, 8))
+
+	for k := range 100 {
+		idx := pl.HashPartition(k, 3)
+		assert.True(t, idx >= 0 && idx < 3)
+	}
+}
@@ -8,7 +8,14 @@ import (
 func NewPipeline(parent context.Context) (context.Context, context.CancelFunc) {
 	wg := &sync.WaitGroup{}
 	ctxWg := context.WithValue(parent, waitGroupKey, wg)
-	ctx, cancel := context.WithCancel(ctxWg)
+	ctxStats := context.WithValue(ctxWg, statsKey, &statsRegistry{})
+	ctxErrors := context.WithValue(ctxStats, errorsKey, &errorCollector{})
+	drain := &drainState{draining: NewSignal()}
+	ctxDrain := context.WithValue(ctxErrors, drainKey, drain)
+	ctxTasks := context.WithValue(ctxDrain, tasksKey, newTaskRegistry())
+	ctxLinks := context.WithValue(ctxTasks, linksKey, &linkRegistry{})
+	ctx, cancel := context.WithCancel(ctxLinks)
+	drain.cancel = cancel
 
 	// wait goroutines shutdown on cancel
 	return ctx, func() {
@@ -24,19 +31,32 @@ func shutdown(wg *sync.WaitGroup, cancel context.CancelFunc) {
 
 type contextKey int
 
-const waitGroupKey contextKey = 0
+const (
+	waitGroupKey contextKey = iota
+	panicHandlerKey
+	statsKey
+	optionsKey
+	tracerKey
+	errorsKey
+	drainKey
+	tasksKey
+	clockKey
+	linksKey
+)
 
 func getWaitGroup(ctx context.Context) (opt optWaitGroup) {
 	r := ctx.Value(waitGroupKey)
 	if r != nil {
 		opt.wg = r.(*sync.WaitGroup)
+		opt.tasks, _ = ctx.Value(tasksKey).(*taskRegistry)
 	}
 	return
 }
 
 // optional wait group, do nothing if context was create without `NewPipeline`
 type optWaitGroup struct {
-	wg *sync.WaitGroup
+	wg    *sync.WaitGroup
+	tasks *taskRegistry // running goroutines, see `CancelTimeout`
 }
 
 func (opt optWaitGroup) Add(delta int) {
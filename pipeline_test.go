@@ -3,55 +3,12 @@ package pipeline_test
 import (
 	"context"
 	"testing"
-	"time"
 
 	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
 	"github.com/stretchr/testify/assert"
 )
 
-func withTimeout(t *testing.T, context string, cb func()) {
-	t.Helper()
-
-	finished := pl.NewSignal()
-	go func() {
-		cb()
-		finished.Set()
-	}()
-
-	if !finished.TryWait(time.Second) {
-		t.Fatalf("%s: timeout", context)
-	}
-}
-
-func checkShutdown(t *testing.T, cancel context.CancelFunc) {
-	t.Helper()
-
-	withTimeout(t, "pipline shutdown", func() {
-		cancel()
-	})
-}
-
-func checkRead[T any](t *testing.T, ch <-chan T) T {
-	t.Helper()
-
-	type result struct {
-		val T
-		ok  bool
-	}
-
-	chres := make(chan result, 1)
-	withTimeout(t, "read channel", func() {
-		v, ok := <-ch
-		chres <- result{v, ok}
-	})
-
-	r := <-chres
-	if !r.ok {
-		t.Fatalf("channel was unexpectedly closed")
-	}
-	return r.val
-}
-
 func TestPipelineShutdown(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
 
@@ -67,9 +24,9 @@ func TestPipelineShutdown(t *testing.T) {
 	}()
 
 	// wait for all spawned goroutines to exit
-	checkPending(t, shutdownFinished)
+	pipelinetest.CheckPending(t, shutdownFinished)
 	exit.Set()
-	checkSignaled(t, shutdownFinished)
+	pipelinetest.CheckSignaled(t, shutdownFinished)
 }
 
 func TestPipelineIsOptional(t *testing.T) {
@@ -102,14 +59,14 @@ func TestPipelineIsOptional(t *testing.T) {
 		return sum
 	})
 
-	withTimeout(t, "waiting collection", func() {
+	pipelinetest.WithTimeout(t, "waiting collection", func() {
 		foundStrangeNumber.Wait()
 	})
 
-	checkPending(t, res)
+	pipelinetest.CheckPending(t, res)
 	cancel()
 	continueCollect.Set()
 
-	v := checkRead(t, res)
+	v := pipelinetest.CheckRead(t, res)
 	assert.Equal(t, 861, v) // sum from 0 to 42
 }
@@ -0,0 +1,177 @@
+package pipelinetest
+
+import (
+	"sync"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+)
+
+// manual clock for deterministic tests, time changes only by `Advance`
+//
+//	clock := pipelinetest.NewFakeClock(time.Time{})
+//	ctx, cancel := pl.NewPipeline(pl.WithClock(context.Background(), clock))
+//	...
+//	clock.BlockUntilTimers(1) // wait until stage starts its timer
+//	clock.Advance(time.Second)
+type FakeClock struct {
+	mu      sync.Mutex
+	changed *sync.Cond // timer is added
+	now     time.Time
+	timers  map[*fakeTimer]struct{} // active timers and tickers
+}
+
+func NewFakeClock(start time.Time) *FakeClock {
+	c := &FakeClock{
+		now:    start,
+		timers: make(map[*fakeTimer]struct{}),
+	}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) pl.Timer {
+	return c.newTimer(d, 0)
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) pl.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return fakeTicker{c.newTimer(d, d)}
+}
+
+// move time forward firing all timers that expire meanwhile in their order
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	target := c.now.Add(d)
+	for {
+		var next *fakeTimer
+		for t := range c.timers {
+			if !t.when.After(target) && (next == nil || t.when.Before(next.when)) {
+				next = t
+			}
+		}
+
+		if next == nil {
+			break
+		}
+
+		if next.when.After(c.now) {
+			c.now = next.when
+		}
+		next.fire(c.now)
+	}
+
+	c.now = target
+}
+
+// wait until at least `n` timers and tickers are active
+func (c *FakeClock) BlockUntilTimers(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.timers) < n {
+		c.changed.Wait()
+	}
+}
+
+// number of active timers and tickers
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func (c *FakeClock) newTimer(d time.Duration, period time.Duration) *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{
+		clock:  c,
+		ch:     make(chan time.Time, 1),
+		period: period,
+	}
+	t.start(d)
+	return t
+}
+
+type fakeTimer struct {
+	clock  *FakeClock
+	ch     chan time.Time
+	when   time.Time
+	period time.Duration // 0 for timers
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.stop()
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	active := t.stop()
+	t.start(d)
+	return active
+}
+
+type fakeTicker struct {
+	*fakeTimer
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeTimer.Stop()
+}
+
+// must be called with `clock.mu` locked
+func (t *fakeTimer) start(d time.Duration) {
+	t.when = t.clock.now.Add(d)
+	t.clock.timers[t] = struct{}{}
+	t.clock.changed.Broadcast()
+
+	if d <= 0 && t.period == 0 {
+		t.fire(t.clock.now)
+	}
+}
+
+// like timers since Go 1.23, no stale value is received after `Stop`;
+// must be called with `clock.mu` locked
+func (t *fakeTimer) stop() bool {
+	_, active := t.clock.timers[t]
+	delete(t.clock.timers, t)
+
+	select {
+	case <-t.ch:
+	default:
+	}
+	return active
+}
+
+// must be called with `clock.mu` locked
+func (t *fakeTimer) fire(now time.Time) {
+	select {
+	case t.ch <- now:
+	default: // ticker drops ticks for slow receivers
+	}
+
+	if t.period > 0 {
+		t.when = t.when.Add(t.period)
+	} else {
+		delete(t.clock.timers, t)
+	}
+}
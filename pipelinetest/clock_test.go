@@ -0,0 +1,61 @@
+package pipelinetest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock_Timer(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := pipelinetest.NewFakeClock(start)
+
+	timer := clock.NewTimer(time.Second)
+	assert.Equal(t, 1, clock.Timers())
+
+	clock.Advance(999 * time.Millisecond)
+	pipelinetest.CheckPending(t, timer.C())
+
+	clock.Advance(time.Millisecond)
+	assert.Equal(t, start.Add(time.Second), pipelinetest.CheckRead(t, timer.C()))
+	assert.Equal(t, 0, clock.Timers())
+	assert.False(t, timer.Stop())
+
+	assert.False(t, timer.Reset(time.Second))
+	assert.True(t, timer.Stop())
+	clock.Advance(time.Hour)
+	pipelinetest.CheckPending(t, timer.C())
+}
+
+func TestFakeClock_Ticker(t *testing.T) {
+	clock := pipelinetest.NewFakeClock(time.Time{})
+
+	ticker := clock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	clock.Advance(time.Second)
+	assert.Equal(t, time.Time{}.Add(time.Second), pipelinetest.CheckRead(t, ticker.C()))
+
+	// ticks are dropped if nobody reads them
+	clock.Advance(5 * time.Second)
+	assert.Equal(t, time.Time{}.Add(2*time.Second), pipelinetest.CheckRead(t, ticker.C()))
+	pipelinetest.CheckPending(t, ticker.C())
+	assert.Equal(t, time.Time{}.Add(6*time.Second), clock.Now())
+}
+
+func TestFakeClock_BlockUntilTimers(t *testing.T) {
+	clock := pipelinetest.NewFakeClock(time.Time{})
+
+	fired := make(chan time.Time)
+	go func() {
+		fired <- <-clock.NewTimer(time.Minute).C()
+	}()
+
+	pipelinetest.WithTimeout(t, "wait timer", func() {
+		clock.BlockUntilTimers(1)
+	})
+	clock.Advance(time.Minute)
+	pipelinetest.CheckRead(t, fired)
+}
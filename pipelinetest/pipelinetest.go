@@ -0,0 +1,171 @@
+// test helpers for pipelines and custom stages
+package pipelinetest
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+)
+
+// how long helpers wait before failing the test, see `SetTimeout`
+const DefaultTimeout = time.Second
+
+var timeouts sync.Map // `testing.TB` -> `time.Duration`
+
+// set how long helpers wait before failing test `t`, other tests are not affected,
+// so it's safe to call it in parallel tests; it's reset when the test is finished
+func SetTimeout(t testing.TB, d time.Duration) {
+	timeouts.Store(t, d)
+	t.Cleanup(func() { timeouts.Delete(t) })
+}
+
+func timeout(t testing.TB) time.Duration {
+	if d, ok := timeouts.Load(t); ok {
+		return d.(time.Duration)
+	}
+	return DefaultTimeout
+}
+
+// run `cb` and fail the test if it isn't finished in time, see `SetTimeout`
+//
+// `cb` must not call `t.Fatal`, it's running in a separate goroutine
+func WithTimeout(t testing.TB, what string, cb func()) {
+	t.Helper()
+
+	finished := pl.NewSignal()
+	go func() {
+		defer finished.Set()
+		cb()
+	}()
+
+	// timeout is measured in real time, even if test uses fake clock
+	if !finished.TryWait(timeout(t)) {
+		t.Fatalf("%s: timeout", what)
+	}
+}
+
+// call pipeline `cancel` and check that all goroutines are exited
+func CheckShutdown(t testing.TB, cancel context.CancelFunc) {
+	t.Helper()
+	WithTimeout(t, "pipeline shutdown", cancel)
+}
+
+// cancel pipeline created with `ctx` and check that it leaves no goroutines behind,
+// the test fails with the list of running goroutines and their stacks otherwise
+func CheckNoLeaks(t testing.TB, ctx context.Context) {
+	t.Helper()
+
+	if err := pl.CancelTimeout(ctx, timeout(t)); err != nil {
+		t.Fatalf("pipeline leaks goroutines: %v", err)
+	}
+}
+
+// check that channel has no value and isn't closed, e.g. `Signal` isn't triggered
+func CheckPending[T any](t testing.TB, ch <-chan T) {
+	t.Helper()
+
+	select {
+	case val, ok := <-ch:
+		if !ok {
+			t.Fatalf("channel was closed")
+		}
+		t.Fatalf("channel is not in a pending state (received %v)", val)
+	default:
+		// success
+	}
+}
+
+// check that channel has a value or is closed in time, e.g. `Signal` is triggered
+func CheckSignaled[T any](t testing.TB, ch <-chan T) {
+	t.Helper()
+
+	select {
+	case <-ch:
+		// success
+	case <-time.After(timeout(t)):
+		t.Fatalf("channel is not in a signaled state")
+	}
+}
+
+// read a value in time, fail if channel is closed (e.g. read `Oneshot` value)
+func CheckRead[T any](t testing.TB, ch <-chan T) T {
+	t.Helper()
+
+	select {
+	case v, ok := <-ch:
+		if !ok {
+			t.Fatalf("channel was unexpectedly closed")
+		}
+		return v
+	case <-time.After(timeout(t)):
+		t.Fatalf("read channel: timeout")
+		var empty T
+		return empty
+	}
+}
+
+// read all values until channel is closed, fail if it isn't closed in time
+func ReadAll[T any](t testing.TB, ch <-chan T) []T {
+	t.Helper()
+
+	var res []T
+	expired := time.After(timeout(t))
+	for {
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				return res
+			}
+			res = append(res, v)
+		case <-expired:
+			t.Fatalf("read all values: timeout (received %v)", res)
+		}
+	}
+}
+
+// check that stream produces exactly `expected` values in that order and is closed
+func CheckSequence[T comparable](t testing.TB, ch <-chan T, expected ...T) {
+	t.Helper()
+
+	got := ReadAll(t, ch)
+	if !slices.Equal(got, expected) {
+		t.Fatalf("sequence mismatch:\n  expected: %v\n  received: %v", expected, got)
+	}
+}
+
+// check that stream produces exactly `expected` values in any order and is closed
+func CheckUnordered[T comparable](t testing.TB, ch <-chan T, expected ...T) {
+	t.Helper()
+
+	got := ReadAll(t, ch)
+
+	counts := make(map[T]int, len(expected))
+	for _, v := range expected {
+		counts[v] += 1
+	}
+
+	var extra []T
+	for _, v := range got {
+		if counts[v] == 0 {
+			extra = append(extra, v)
+			continue
+		}
+		counts[v] -= 1
+	}
+
+	var missing []T
+	for _, v := range expected {
+		if counts[v] > 0 {
+			missing = append(missing, v)
+			counts[v] -= 1
+		}
+	}
+
+	if len(extra) != 0 || len(missing) != 0 {
+		t.Fatalf("unordered sequence mismatch:\n  missing: %v\n  unexpected: %v\n  received: %v", missing, extra, got)
+	}
+}
@@ -0,0 +1,136 @@
+package pipelinetest_test
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// `testing.TB` that records the failure instead of failing the test
+type recorder struct {
+	testing.TB
+	mu     sync.Mutex
+	failed string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.mu.Lock()
+	r.failed = fmt.Sprintf(format, args...)
+	r.mu.Unlock()
+	runtime.Goexit()
+}
+
+// run check in a separate goroutine, because `Fatalf` exits it;
+// helpers wait only a short time, since checks are expected to fail
+func failure(t *testing.T, check func(t testing.TB)) string {
+	r := &recorder{TB: t}
+	pipelinetest.SetTimeout(r, 10*time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		check(r)
+	}()
+	<-done
+	return r.failed
+}
+
+func sequence(ctx context.Context, count int) <-chan int {
+	return pl.Generate(ctx, func(w pl.Writer[int]) {
+		for k := range count {
+			if !w.Write(k) {
+				return
+			}
+		}
+	})
+}
+
+func TestChecks(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	sig := pl.NewSignal()
+	pipelinetest.CheckPending(t, sig)
+	sig.Set()
+	pipelinetest.CheckSignaled(t, sig)
+
+	val, cherr := pl.CollectErr(ctx, func() (int, error) { return 42, nil })
+	assert.Equal(t, 42, pipelinetest.CheckRead(t, val))
+	pipelinetest.CheckPending(t, cherr)
+
+	pipelinetest.CheckSequence(t, sequence(ctx, 3), 0, 1, 2)
+
+	res := pl.Transform(ctx, 4, sequence(ctx, 5), func(x int) int { return x })
+	pipelinetest.CheckUnordered(t, res, 4, 3, 2, 1, 0)
+
+	assert.Equal(t, []int{0, 1}, pipelinetest.ReadAll(t, sequence(ctx, 2)))
+}
+
+func TestChecks_Failures(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	sig := pl.NewSignal()
+	assert.Equal(t, "channel is not in a signaled state", failure(t, func(t testing.TB) {
+		pipelinetest.CheckSignaled(t, sig)
+	}))
+
+	sig.Set()
+	assert.Equal(t, "channel was closed", failure(t, func(t testing.TB) {
+		pipelinetest.CheckPending(t, sig)
+	}))
+
+	assert.Equal(t, "read channel: timeout", failure(t, func(t testing.TB) {
+		pipelinetest.CheckRead(t, make(chan int))
+	}))
+
+	assert.Contains(t, failure(t, func(t testing.TB) {
+		pipelinetest.CheckSequence(t, sequence(ctx, 3), 0, 2, 1)
+	}), "expected: [0 2 1]\n  received: [0 1 2]")
+
+	assert.Contains(t, failure(t, func(t testing.TB) {
+		pipelinetest.CheckUnordered(t, sequence(ctx, 3), 0, 1, 1)
+	}), "missing: [1]\n  unexpected: [2]")
+
+	assert.Equal(t, "stuck: timeout", failure(t, func(t testing.TB) {
+		pipelinetest.WithTimeout(t, "stuck", func() { <-ctx.Done() })
+	}))
+}
+
+func TestSetTimeout(t *testing.T) {
+	t.Parallel() // timeout is set per test
+
+	// `failure` sets a short timeout of the recorder
+	start := time.Now()
+	assert.Equal(t, "read channel: timeout", failure(t, func(rt testing.TB) {
+		pipelinetest.CheckRead(rt, make(chan int))
+	}))
+	assert.Less(t, time.Since(start), pipelinetest.DefaultTimeout)
+}
+
+func TestCheckNoLeaks(t *testing.T) {
+	ctx, _ := pl.NewPipeline(context.Background())
+	_ = pl.Process(ctx, 2, sequence(ctx, 10), func(int) {})
+	pipelinetest.CheckNoLeaks(t, ctx)
+
+	ctx, cancel := pl.NewPipeline(context.Background())
+	release := pl.NewSignal()
+	defer func() {
+		release.Set()
+		pipelinetest.CheckShutdown(t, cancel)
+	}()
+
+	pl.Go(ctx, func() { release.Wait() })
+	assert.Contains(t, failure(t, func(t testing.TB) {
+		pipelinetest.CheckNoLeaks(t, ctx)
+	}), "pipeline leaks goroutines: shutdown timeout: 1 goroutines are still running")
+}
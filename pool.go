@@ -0,0 +1,242 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// handle to change the number of stage workers at runtime, see `Pool` option
+//
+// zero value is ready to use, a pool can be attached to several stages,
+// each of them runs `Size` workers then
+type WorkerPool struct {
+	mu     sync.Mutex
+	groups []resizer
+	size   int // requested size, 0 if pool was not resized or attached yet
+}
+
+type resizer interface {
+	resize(n int)
+	size() int
+	running() int
+}
+
+// change the number of workers, `n` less than 1 is treated as 1
+//
+// extra workers exit after processing their current item, idle workers exit immediately
+func (p *WorkerPool) Resize(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.size = max(n, 1)
+	for _, group := range p.groups {
+		group.resize(p.size)
+	}
+}
+
+// requested number of workers of each stage,
+// the largest one if stages were resized differently by `Autoscale`
+func (p *WorkerPool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.groups) == 0 {
+		return p.size
+	}
+
+	size := 0
+	for _, group := range p.groups {
+		size = max(size, group.size())
+	}
+	return size
+}
+
+// number of currently running workers of all stages, it differs from `Size`
+// while extra workers finish their items or after the stages are finished
+func (p *WorkerPool) Running() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	count := 0
+	for _, group := range p.groups {
+		count += group.running()
+	}
+	return count
+}
+
+func (p *WorkerPool) attach(group resizer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.groups = append(p.groups, group)
+	if p.size != 0 {
+		group.resize(p.size)
+	} else {
+		p.size = group.size()
+	}
+}
+
+// attach worker pool handle to `Transform` or `Process` stage
+//
+// `threads` argument sets the initial size unless the pool was resized
+// or attached to another stage before
+func Pool(p *WorkerPool) Option {
+	return func(cfg *stageConfig) {
+		cfg.pool = p
+	}
+}
+
+// resize `Transform` or `Process` workers according to the input backlog
+//
+// every `interval` one worker is added if there are items waiting in the input
+// channel and all workers are busy, and one is removed if some workers wait for input;
+// the backlog is measured by the input channel length, so it must be buffered (see `Buffer`)
+//
+// `interval` is 100ms if not set
+func Autoscale(minThreads, maxThreads int, interval time.Duration) Option {
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+
+	return func(cfg *stageConfig) {
+		cfg.autoscale = &autoscaleConfig{
+			min:      max(minThreads, 1),
+			max:      max(minThreads, maxThreads, 1),
+			interval: interval,
+		}
+	}
+}
+
+type autoscaleConfig struct {
+	min      int
+	max      int
+	interval time.Duration
+}
+
+// resizable set of stage workers
+type workerGroup struct {
+	mu      sync.Mutex
+	target  int
+	count   int
+	stopped bool          // all workers have exited, the group can't grow anymore
+	wake    chan struct{} // closed when group is downsized, so idle workers check `retire`
+	spawn   func()
+
+	waiting  atomic.Int64 // workers that wait for input
+	finished SignalMut
+}
+
+func newWorkerGroup(spawn func()) *workerGroup {
+	return &workerGroup{
+		spawn:    spawn,
+		wake:     make(chan struct{}),
+		finished: NewSignal(),
+	}
+}
+
+func (g *workerGroup) resize(n int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.target = max(n, 1)
+	if g.stopped {
+		return
+	}
+
+	for g.count < g.target {
+		g.count += 1
+		g.spawn()
+	}
+
+	if g.count > g.target {
+		close(g.wake)
+		g.wake = make(chan struct{})
+	}
+}
+
+func (g *workerGroup) size() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.target
+}
+
+func (g *workerGroup) running() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.count
+}
+
+// check whether the calling worker must exit due to downsize,
+// otherwise idle worker must wait for input until `wake` is closed
+func (g *workerGroup) retire() (bool, <-chan struct{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.count > g.target {
+		g.count -= 1
+		return true, nil
+	}
+	return false, g.wake
+}
+
+// wait the next job of a worker of `g`, `woken` is set if the group was downsized,
+// so the worker must check `retire`; `ok` is false if `jobs` is closed or on cancellation
+func readJob[J any](ctx context.Context, g *workerGroup, wake <-chan struct{}, jobs <-chan J) (job J, ok bool, woken bool) {
+	g.waiting.Add(1)
+	defer g.waiting.Add(-1)
+
+	select {
+	case job, ok = <-jobs:
+	case <-wake:
+		woken = true
+	case <-ctx.Done():
+	}
+	return
+}
+
+// must be called when worker exits by itself (i.e. not retired)
+func (g *workerGroup) exit() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.count -= 1
+	if g.count == 0 {
+		g.stopped = true
+		g.finished.Set()
+	}
+}
+
+// wait all workers to exit
+func (g *workerGroup) wait() {
+	g.finished.Wait()
+}
+
+// periodically resize the group according to `backlog` of the input
+func (g *workerGroup) autoscale(ctx context.Context, o origin, cfg *autoscaleConfig, backlog func() int) {
+	spawn(ctx, o, func() {
+		ticker := GetClock(ctx).NewTicker(cfg.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+			case <-g.finished:
+				return
+			case <-ctx.Done():
+				return
+			}
+
+			size := g.size()
+			waiting := int(g.waiting.Load())
+
+			switch {
+			case backlog() > 0 && waiting == 0 && size < cfg.max:
+				g.resize(size + 1)
+			case waiting > 0 && size > cfg.min:
+				g.resize(size - 1)
+			}
+		}
+	})
+}
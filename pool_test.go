@@ -0,0 +1,225 @@
+package pipeline_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// callback that blocks until released and counts concurrent calls
+type blocker struct {
+	active  atomic.Int32
+	release pl.SignalMut
+}
+
+func newBlocker() *blocker {
+	return &blocker{release: pl.NewSignal()}
+}
+
+func (b *blocker) Call(x int) int {
+	b.active.Add(1)
+	defer b.active.Add(-1)
+	b.release.Wait()
+	return x
+}
+
+func waitActive(t *testing.T, b *blocker, n int32) {
+	t.Helper()
+
+	pipelinetest.WithTimeout(t, "wait active workers", func() {
+		for b.active.Load() != n {
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
+
+func TestWorkerPool_Resize(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan int, 100)
+	for k := range 100 {
+		input <- k
+	}
+	close(input)
+
+	pool := &pl.WorkerPool{}
+	b := newBlocker()
+	res := pl.Transform(ctx, 2, input, b.Call, pl.Pool(pool))
+
+	waitActive(t, b, 2)
+	assert.Equal(t, 2, pool.Size())
+	assert.Equal(t, 2, pool.Running())
+
+	pool.Resize(5)
+	waitActive(t, b, 5)
+	assert.Equal(t, 5, pool.Running())
+
+	pool.Resize(1)
+	assert.Equal(t, 1, pool.Size())
+
+	b.release.Set()
+
+	pipelinetest.WithTimeout(t, "read results", func() {
+		count := 0
+		for range res {
+			count += 1
+		}
+		assert.Equal(t, 100, count)
+	})
+
+	assert.Equal(t, 0, pool.Running())
+}
+
+func TestWorkerPool_ResizeBeforeStart(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan int, 10)
+	for k := range 10 {
+		input <- k
+	}
+
+	pool := &pl.WorkerPool{}
+	pool.Resize(3)
+
+	b := newBlocker()
+	finished := pl.Process(ctx, 1, input, func(x int) { b.Call(x) }, pl.Pool(pool))
+
+	waitActive(t, b, 3)
+
+	b.release.Set()
+	close(input)
+	pipelinetest.CheckSignaled(t, finished)
+}
+
+func TestWorkerPool_Shrink(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan int)
+	pool := &pl.WorkerPool{}
+
+	var mu sync.Mutex
+	release := map[int]pl.SignalMut{}
+	for k := range 4 {
+		release[k] = pl.NewSignal()
+	}
+
+	active := atomic.Int32{}
+	res := pl.Transform(ctx, 4, input, func(x int) int {
+		active.Add(1)
+		defer active.Add(-1)
+
+		mu.Lock()
+		sig := release[x]
+		mu.Unlock()
+
+		sig.Wait()
+		return x
+	}, pl.Pool(pool))
+
+	pipelinetest.WithTimeout(t, "start workers", func() {
+		for k := range 4 {
+			input <- k
+		}
+	})
+
+	pool.Resize(2)
+
+	// workers exit after finishing their items
+	for k := range 2 {
+		release[k].Set()
+		pipelinetest.CheckRead(t, res)
+	}
+
+	pipelinetest.WithTimeout(t, "wait workers exit", func() {
+		for pool.Running() != 2 {
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	release[2].Set()
+	release[3].Set()
+	pipelinetest.CheckRead(t, res)
+	pipelinetest.CheckRead(t, res)
+
+	close(input)
+}
+
+func TestWorkerPool_ShrinkIdle(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	pool := &pl.WorkerPool{}
+	_ = pl.Process(ctx, 4, make(chan int), func(int) {}, pl.Pool(pool))
+	assert.Equal(t, 4, pool.Running())
+
+	// idle workers exit without waiting for the next item
+	pool.Resize(1)
+	pipelinetest.WithTimeout(t, "wait workers exit", func() {
+		for pool.Running() != 1 {
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
+
+func TestWorkerPool_Shared(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	pool := &pl.WorkerPool{}
+	_ = pl.Process(ctx, 2, make(chan int), func(int) {}, pl.Pool(pool))
+	_ = pl.Process(ctx, 1, make(chan int), func(int) {}, pl.Pool(pool))
+
+	// the second stage uses size of the pool
+	assert.Equal(t, 2, pool.Size())
+	assert.Equal(t, 4, pool.Running())
+
+	pool.Resize(3)
+	assert.Equal(t, 3, pool.Size())
+	assert.Equal(t, 6, pool.Running())
+}
+
+func TestAutoscale(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan int, 100)
+	for k := range 100 {
+		input <- k
+	}
+
+	pool := &pl.WorkerPool{}
+	b := newBlocker()
+	res := pl.Transform(ctx, 1, input, b.Call,
+		pl.Pool(pool), pl.Autoscale(1, 4, time.Millisecond))
+
+	// backlog is growing, all workers are busy
+	waitActive(t, b, 4)
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, 4, pool.Size()) // never more than max
+
+	b.release.Set()
+
+	pipelinetest.WithTimeout(t, "read results", func() {
+		for range 100 {
+			<-res
+		}
+	})
+
+	// no backlog, workers are idle
+	pipelinetest.WithTimeout(t, "scale down", func() {
+		for pool.Size() != 1 {
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	close(input)
+}
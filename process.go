@@ -2,77 +2,78 @@ package pipeline
 
 import (
 	"context"
-	"sync"
 	"sync/atomic"
 )
 
-func Process[T any](ctx context.Context, threads int, in <-chan T, cb func(T)) Signal {
-	var wg sync.WaitGroup
-	wg.Add(threads)
+func Process[T any](ctx context.Context, threads int, in <-chan T, cb func(T), opts ...Option) Signal {
+	st := newStage(ctx, "Process", opts)
+	input := newInput(st, in)
 
-	for range threads {
-		Go(ctx, func() {
-			defer wg.Done()
+	handle := processItem(ctx, st, false, OneshotMut[error]{}, nil, func(v T) error {
+		cb(v)
+		return nil
+	})
+	workers := startWorkers(ctx, st, threads, input, handle)
 
-			for {
-				v, ok := Read(ctx, in)
-				if !ok {
-					return
-				}
-
-				cb(v)
-			}
-		})
-	}
-
-	return signalAfterAll(ctx, &wg, nil)
+	return signalAfterAll(ctx, st, workers, nil)
 }
 
-func ProcessErr[T any](ctx context.Context, threads int, in <-chan T, cb func(T) error) (Signal, Oneshot[error]) {
+func ProcessErr[T any](ctx context.Context, threads int, in <-chan T, cb func(T) error, opts ...Option) (Signal, Oneshot[error]) {
+	st := newStage(ctx, "ProcessErr", opts)
+	input := newInput(st, in)
 	cherr := NewOneshotGroup[error](threads) // each worker can send one error
-
-	var wg sync.WaitGroup
-	wg.Add(threads)
+	setupDeadLetters[T](st)
 
 	hasError := atomic.Bool{}
+	handle := processItem(ctx, st, true, cherr, &hasError, cb)
+	workers := startWorkers(ctx, st, threads, input, handle)
 
-	for range threads {
-		Go(ctx, func() {
-			defer wg.Done()
+	finished := signalAfterAll(ctx, st, workers, &hasError)
 
-			for {
-				v, ok := Read(ctx, in)
-				if !ok {
-					return
-				}
+	return finished, cherr.Chan()
+}
 
-				err := cb(v)
-				if err != nil {
-					cherr.Write(err)
-					hasError.Store(true)
-					return
-				}
-			}
+// worker handler of `Process` stages
+//
+// if stage is not `fallible`, only panics are expected, they are reported to the pipeline handler
+// and the item is skipped; otherwise the error is sent to `cherr` and `hasError` is set
+func processItem[T any](ctx context.Context, st *stage, fallible bool, cherr OneshotMut[error], hasError *atomic.Bool, cb func(T) error) func(item[T]) bool {
+	return func(it item[T]) bool {
+		err := st.callRetry(ctx, it.span, func() error {
+			return cb(it.val)
 		})
-	}
+		if err == nil {
+			return true
+		}
 
-	finished := signalAfterAll(ctx, &wg, &hasError)
+		st.fail()
 
-	return finished, cherr.Chan()
+		if !fallible {
+			reportItemPanic(ctx, it.val, err)
+			return true // skip failed item
+		}
+
+		if err = st.deadLetter(ctx, it.val, err); err == nil {
+			return true // failed item is sent to dead letters
+		}
+
+		st.reportError(ctx, err)
+		hasError.Store(true)
+		cherr.tryWrite(err) // `Pool`, `Autoscale` and keyed stages can fail more times than `cherr` holds
+		return false
+	}
 }
 
-func signalAfterAll(ctx context.Context, wg *sync.WaitGroup, hasError *atomic.Bool) Signal {
-	pipelineWg := getWaitGroup(ctx)
+func signalAfterAll(ctx context.Context, st *stage, workers *workerGroup, hasError *atomic.Bool) Signal {
 	finished := NewSignal()
-	pipelineWg.Add(1)
-	go func() {
-		defer pipelineWg.Done()
-		wg.Wait()
+	spawn(ctx, st.origin(), func() {
+		defer st.end()
+		workers.wait()
 
 		if hasError == nil || !hasError.Load() {
 			// note: `finished` never triggered in case of error
 			finished.Set()
 		}
-	}()
+	})
 	return finished.Chan()
 }
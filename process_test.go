@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -28,7 +29,7 @@ func (a *adder) Value() int {
 
 func TestProcess(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	sum := adder{}
 
@@ -37,13 +38,13 @@ func TestProcess(t *testing.T) {
 		sum.Add(x)
 	})
 
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 	assert.Equal(t, sum.Value(), 45)
 }
 
 func TestProcessSpawnWorkers(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	numWorkers := 42
 
@@ -58,7 +59,7 @@ func TestProcessSpawnWorkers(t *testing.T) {
 		stopProcessing.Wait()
 	})
 
-	withTimeout(t, "count spawned workers", func() {
+	pipelinetest.WithTimeout(t, "count spawned workers", func() {
 		for range numWorkers {
 			input <- 42
 		}
@@ -66,15 +67,15 @@ func TestProcessSpawnWorkers(t *testing.T) {
 	})
 
 	stopProcessing.Set()
-	checkPending(t, finished) // network is waiting for new input values
+	pipelinetest.CheckPending(t, finished) // network is waiting for new input values
 
 	close(input)
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 }
 
 func TestProcessErr(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	sum := adder{}
 
@@ -84,15 +85,15 @@ func TestProcessErr(t *testing.T) {
 		return nil
 	})
 
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 	assert.Equal(t, sum.Value(), 45)
 
-	checkPending(t, cherr) // no errors
+	pipelinetest.CheckPending(t, cherr) // no errors
 }
 
 func TestProcessErrSpawnWorkers(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	numWorkers := 42
 
@@ -108,7 +109,7 @@ func TestProcessErrSpawnWorkers(t *testing.T) {
 		return nil
 	})
 
-	withTimeout(t, "count spawned workers", func() {
+	pipelinetest.WithTimeout(t, "count spawned workers", func() {
 		for range numWorkers {
 			input <- 42
 		}
@@ -116,12 +117,12 @@ func TestProcessErrSpawnWorkers(t *testing.T) {
 	})
 
 	stopProcessing.Set()
-	checkPending(t, finished) // network is waiting for more input
+	pipelinetest.CheckPending(t, finished) // network is waiting for more input
 
 	close(input)
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 
-	checkPending(t, cherr) // no errors
+	pipelinetest.CheckPending(t, cherr) // no errors
 }
 
 func TestProcessErrPropagate(t *testing.T) {
@@ -137,20 +138,20 @@ func TestProcessErrPropagate(t *testing.T) {
 		return errTest
 	})
 
-	withTimeout(t, "receive error", func() {
+	pipelinetest.WithTimeout(t, "receive error", func() {
 		for range numWorkers {
 			input <- 42
 		}
 
 		doFail.Set()
 
-		err := checkRead(t, cherr)
+		err := pipelinetest.CheckRead(t, cherr)
 		assert.Equal(t, err, errTest)
 	})
 
-	checkPending(t, finished) // nothing was processed, all failed
+	pipelinetest.CheckPending(t, finished) // nothing was processed, all failed
 
 	// note: multiple errors were emitted simultaneously,
 	// make sure that no goroutine was stuck
-	checkShutdown(t, cancel)
+	pipelinetest.CheckShutdown(t, cancel)
 }
@@ -6,16 +6,17 @@ import (
 	"testing"
 
 	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestWrite(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	recv := make(chan int, 10)
 
-	withTimeout(t, "write values", func() {
+	pipelinetest.WithTimeout(t, "write values", func() {
 		for k := range cap(recv) {
 			ok := pl.Write(ctx, recv, k)
 			assert.True(t, ok)
@@ -32,12 +33,12 @@ func TestWrite_DontStuck(t *testing.T) {
 		assert.False(t, ok)
 	})
 
-	checkShutdown(t, cancel)
+	pipelinetest.CheckShutdown(t, cancel)
 }
 
 func TestRead(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	vals := make(chan int, 10)
 	for k := range cap(vals) {
@@ -45,7 +46,7 @@ func TestRead(t *testing.T) {
 	}
 	close(vals)
 
-	withTimeout(t, "read values", func() {
+	pipelinetest.WithTimeout(t, "read values", func() {
 		for k := range cap(vals) {
 			v, ok := pl.Read(ctx, vals)
 			assert.True(t, ok)
@@ -71,13 +72,13 @@ func TestRead_NeverStuck(t *testing.T) {
 		finished.Set()
 	})
 
-	checkShutdown(t, cancel)
-	checkSignaled(t, finished)
+	pipelinetest.CheckShutdown(t, cancel)
+	pipelinetest.CheckSignaled(t, finished)
 }
 
 func TestReadErr(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	res := make(chan int)
 
@@ -90,16 +91,16 @@ func TestReadErr(t *testing.T) {
 		assert.Equal(t, v, 42)
 	})
 
-	checkPending(t, finished)
+	pipelinetest.CheckPending(t, finished)
 	res <- 42
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 }
 
 func TestReadErr_FinishWithError(t *testing.T) {
 	for index := range 10 {
 		t.Run(fmt.Sprintf("fail on errs[%d]", index), func(t *testing.T) {
 			ctx, cancel := pl.NewPipeline(context.Background())
-			defer checkShutdown(t, cancel)
+			defer pipelinetest.CheckShutdown(t, cancel)
 
 			res := make(chan int)
 
@@ -118,16 +119,16 @@ func TestReadErr_FinishWithError(t *testing.T) {
 				assert.Equal(t, err, errTest)
 			})
 
-			checkPending(t, finished)
+			pipelinetest.CheckPending(t, finished)
 			willFail <- errTest
-			checkSignaled(t, finished)
+			pipelinetest.CheckSignaled(t, finished)
 		})
 	}
 }
 
 func TestReadErr_ReportChannelClosed(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	res := make(chan int)
 
@@ -139,14 +140,14 @@ func TestReadErr_ReportChannelClosed(t *testing.T) {
 		assert.Equal(t, err, pl.ErrChannelClosed)
 	})
 
-	checkPending(t, finished)
+	pipelinetest.CheckPending(t, finished)
 	close(res)
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 }
 
 func TestReadErr_ErrorsCanClose(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	res := make(chan int)
 
@@ -159,10 +160,10 @@ func TestReadErr_ErrorsCanClose(t *testing.T) {
 	})
 
 	close(err1)
-	checkPending(t, finished)
+	pipelinetest.CheckPending(t, finished)
 
 	err2 <- errTest
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 }
 
 func TestReadErr_ReportCancelled(t *testing.T) {
@@ -179,15 +180,15 @@ func TestReadErr_ReportCancelled(t *testing.T) {
 		finished.Set()
 	}()
 
-	checkPending(t, finished)
-	checkShutdown(t, cancel)
+	pipelinetest.CheckPending(t, finished)
+	pipelinetest.CheckShutdown(t, cancel)
 
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 }
 
 func TestReadErr_FallbackToRead(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	res := make(chan int)
 	err1 := make(chan error)
@@ -201,20 +202,20 @@ func TestReadErr_FallbackToRead(t *testing.T) {
 		finished.Set()
 	}()
 
-	checkPending(t, finished)
+	pipelinetest.CheckPending(t, finished)
 
 	// close both error channels, it still must wait for value
 	close(err1)
 	close(err2)
-	checkPending(t, finished)
+	pipelinetest.CheckPending(t, finished)
 
 	res <- 42
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 }
 
 func TestReadErr_ReportWhanAllClosed(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	res := make(chan int)
 	err1 := make(chan error)
@@ -227,14 +228,14 @@ func TestReadErr_ReportWhanAllClosed(t *testing.T) {
 		finished.Set()
 	}()
 
-	checkPending(t, finished)
+	pipelinetest.CheckPending(t, finished)
 
 	close(err1)
 	close(err2)
-	checkPending(t, finished)
+	pipelinetest.CheckPending(t, finished)
 
 	close(res)
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 }
 
 func TestReadErr_ReturnErrorCause(t *testing.T) {
@@ -250,7 +251,7 @@ func TestReadErr_ReturnErrorCause(t *testing.T) {
 		finished.Set()
 	}()
 
-	checkPending(t, finished)
+	pipelinetest.CheckPending(t, finished)
 	cancel(errTest)
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 }
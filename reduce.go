@@ -0,0 +1,143 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// fold items of `in` in parallel: each of `threads` workers accumulates its own partial result
+// starting from `zero`, partial results are merged by `combine` when `in` is closed
+//
+// items are spread over partial results in any order and partials are combined in any order,
+// so `combine` must be associative and commutative (e.g. sum, max or union of sets)
+//
+// `zero` is copied to each worker, so it must not be shared state that `accumulate` modifies
+// (e.g. a map); `zero` is the result if `in` is empty; nothing is written on cancellation,
+// the result is closed without a value if `combine` panics
+func Reduce[T any, A any](ctx context.Context, threads int, in <-chan T, zero A, accumulate func(A, T) A, combine func(A, A) A, opts ...Option) Oneshot[A] {
+	res, _ := reduce(ctx, "Reduce", threads, in, zero, false, opts, func(acc A, v T) (A, error) {
+		return accumulate(acc, v), nil
+	}, func(a A, b A) (A, error) {
+		return combine(a, b), nil
+	})
+	return res
+}
+
+// fallible version of `Reduce`, nothing is written to the result on error
+func ReduceErr[T any, A any](ctx context.Context, threads int, in <-chan T, zero A, accumulate func(A, T) (A, error), combine func(A, A) (A, error), opts ...Option) (Oneshot[A], Oneshot[error]) {
+	return reduce(ctx, "ReduceErr", threads, in, zero, true, opts, accumulate, combine)
+}
+
+// partial results of `reduce` that are not used by workers now
+type partials[A any] struct {
+	mu   sync.Mutex
+	zero A
+	free []A
+}
+
+func (p *partials[A]) get() A {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.free) == 0 {
+		return p.zero
+	}
+
+	acc := p.free[len(p.free)-1]
+	p.free = p.free[:len(p.free)-1]
+	return acc
+}
+
+func (p *partials[A]) put(acc A) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.free = append(p.free, acc)
+}
+
+// if stage is not `fallible`, only panics are expected, they are reported to the pipeline handler,
+// item is skipped on `accumulate` panic and the result is closed on `combine` panic
+func reduce[T any, A any](ctx context.Context, api string, threads int, in <-chan T, zero A, fallible bool, opts []Option, accumulate func(A, T) (A, error), combine func(A, A) (A, error)) (Oneshot[A], Oneshot[error]) {
+	st := newStage(ctx, api, opts)
+	input := newInput(st, in)
+	out := NewOneshot[A]()
+
+	var cherr OneshotMut[error]
+	if fallible {
+		cherr = NewOneshotGroup[error](max(threads, 1)) // each worker can send one error, `combine` is called only if none did
+		setupDeadLetters[T](st)
+	}
+
+	hasError := atomic.Bool{}
+	parts := &partials[A]{zero: zero}
+
+	workers := startWorkers(ctx, st, threads, input, func(it item[T]) bool {
+		acc := parts.get()
+		defer func() { parts.put(acc) }()
+
+		err := st.callRetry(ctx, it.span, func() error {
+			r, err := accumulate(acc, it.val)
+			if err == nil {
+				acc = r
+			}
+			return err
+		})
+		if err == nil {
+			return true
+		}
+
+		st.fail()
+
+		if !fallible {
+			reportItemPanic(ctx, it.val, err)
+			return true // skip failed item
+		}
+
+		if err = st.deadLetter(ctx, it.val, err); err == nil {
+			return true // failed item is sent to dead letters
+		}
+
+		st.reportError(ctx, err)
+		hasError.Store(true)
+		cherr.tryWrite(err) // `Pool` and `Autoscale` can run more workers than `cherr` holds
+		return false
+	})
+
+	spawn(ctx, st.origin(), func() {
+		defer st.end()
+		workers.wait()
+
+		if hasError.Load() || ctx.Err() != nil {
+			return // workers could stop before `in` is closed
+		}
+
+		// note: all workers are finished, so partials are not used anymore
+		if len(parts.free) == 0 {
+			out.Write(zero)
+			return
+		}
+
+		r := parts.free[0]
+		for _, acc := range parts.free[1:] {
+			err := st.call(0, func() (err error) {
+				r, err = combine(r, acc)
+				return
+			})
+			if err != nil {
+				st.fail()
+				if fallible {
+					st.reportError(ctx, err)
+					cherr.Write(err)
+				} else {
+					reportPanic(ctx, err)
+					out.close() // there is no error channel to tell readers that result is lost
+				}
+				return
+			}
+		}
+
+		out.Write(r)
+	})
+
+	return out.Chan(), cherr.Chan()
+}
@@ -0,0 +1,175 @@
+package pipeline_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func sum(a, b int) int {
+	return a + b
+}
+
+func TestReduce(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	res := pl.Reduce(ctx, 4, sequence(ctx, 0, 1000), 0, sum, sum)
+
+	v, err := pl.ReadErr(ctx, res)
+	assert.NoError(t, err)
+	assert.Equal(t, 499500, v)
+}
+
+func TestReduce_Partials(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	combined := atomic.Int32{}
+	res := pl.Reduce(ctx, 4, sequence(ctx, 0, 100), nil, func(acc []int, v int) []int {
+		return append(acc, v)
+	}, func(a, b []int) []int {
+		combined.Add(1)
+		return append(a, b...)
+	})
+
+	r := pipelinetest.CheckRead(t, res)
+	assert.Len(t, r, 100)
+	for k := range 100 {
+		assert.Contains(t, r, k)
+	}
+
+	// one partial result per worker at most
+	assert.LessOrEqual(t, combined.Load(), int32(3))
+}
+
+func TestReduce_Empty(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan int)
+	close(input)
+
+	res := pl.Reduce(ctx, 4, input, 42, sum, sum)
+	assert.Equal(t, 42, pipelinetest.CheckRead(t, res))
+}
+
+func TestReduce_Panic(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(pl.WithPanicHandler(context.Background(), func(err *pl.PanicError) {}))
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	res := pl.Reduce(ctx, 2, sequence(ctx, 0, 10), 0, func(acc, v int) int {
+		if v == 5 {
+			panic("test")
+		}
+		return acc + v
+	}, sum)
+
+	// failed item is skipped
+	assert.Equal(t, 40, pipelinetest.CheckRead(t, res))
+}
+
+func TestReduce_Cancel(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	res := pl.Reduce(ctx, 2, make(chan int), 0, sum, sum)
+	pipelinetest.CheckShutdown(t, cancel)
+
+	pipelinetest.CheckPending(t, res)
+}
+
+func TestReduceErr(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	res, cherr := pl.ReduceErr(ctx, 2, sequence(ctx, 0, 10), 0, func(acc, v int) (int, error) {
+		if v == 5 {
+			return 0, errTest
+		}
+		return acc + v, nil
+	}, func(a, b int) (int, error) {
+		return a + b, nil
+	})
+
+	_, err := pl.ReadErr(ctx, res, cherr)
+	assert.ErrorIs(t, err, errTest)
+	pipelinetest.CheckPending(t, res)
+}
+
+func TestReduceErr_Combine(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	// items are accumulated at the same time, so each one gets its own partial result
+	var started [2]pl.SignalMut
+	for k := range started {
+		started[k] = pl.NewSignal()
+	}
+
+	res, cherr := pl.ReduceErr(ctx, 2, sequence(ctx, 0, 2), 0, func(acc, v int) (int, error) {
+		started[v].Set()
+		started[1-v].Wait()
+		return acc + v, nil
+	}, func(a, b int) (int, error) {
+		return 0, errTest
+	})
+
+	_, err := pl.ReadErr(ctx, res, cherr)
+	assert.ErrorIs(t, err, errTest)
+}
+
+func TestReduce_CombinePanic(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(pl.WithPanicHandler(context.Background(), func(err *pl.PanicError) {}))
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	// items are accumulated at the same time, so each one gets its own partial result
+	var started [2]pl.SignalMut
+	for k := range started {
+		started[k] = pl.NewSignal()
+	}
+
+	res := pl.Reduce(ctx, 2, sequence(ctx, 0, 2), 0, func(acc, v int) int {
+		started[v].Set()
+		started[1-v].Wait()
+		return acc + v
+	}, func(a, b int) int {
+		panic("test")
+	})
+
+	// result is closed without a value
+	assert.Empty(t, pipelinetest.ReadAll(t, res))
+}
+
+func TestReduceErr_Pool(t *testing.T) {
+	ctx, cancel, p := newPanicPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	pool := &pl.WorkerPool{}
+	pool.Resize(4) // more workers than `threads`
+
+	var active atomic.Int32
+	failed := pl.NewSignal()
+	res, cherr := pl.ReduceErr(ctx, 1, sequence(ctx, 0, 4), 0, func(acc, v int) (int, error) {
+		if active.Add(1) == 4 {
+			failed.Set()
+		}
+		failed.Wait() // all workers fail
+		return 0, errTest
+	}, func(a, b int) (int, error) {
+		return a + b, nil
+	}, pl.Pool(pool))
+
+	assert.ErrorIs(t, pipelinetest.CheckRead(t, cherr), errTest)
+	pipelinetest.WithTimeout(t, "wait workers", func() {
+		for pool.Running() != 0 {
+			time.Sleep(time.Millisecond)
+		}
+	})
+	pipelinetest.CheckPending(t, res)
+	assert.Empty(t, p.Get())
+}
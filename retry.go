@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// retry failed items of fallible stages (`TransformErr`, `ProcessErr`, etc.), see `Retry` option
+type RetryPolicy struct {
+	// total number of attempts including the first one
+	MaxAttempts int
+
+	// delay before the first retry, it's multiplied by `Multiplier` after each attempt
+	// and limited by `MaxBackoff` (if set)
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64 // 2 if not set
+
+	// random deviation of the delay in range [0, 1], e.g. 0.1 means +-10%
+	Jitter float64
+
+	// check whether the error is transient, all errors are retried if not set;
+	// panics are never retried
+	Retryable func(error) bool
+}
+
+// retry failed items in place before treating them as failed
+//
+// backoff sleeps are interrupted on pipeline cancellation;
+// note that `FlatMapErr` is not retried, because results of the failed attempt are already emitted
+func Retry(policy RetryPolicy) Option {
+	return func(cfg *stageConfig) {
+		cfg.retry = &policy
+	}
+}
+
+func (p *RetryPolicy) canRetry(attempt int, err error) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+
+	var perr *PanicError
+	if errors.As(err, &perr) {
+		return false
+	}
+
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// delay before the `attempt + 1`
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	mult := p.Multiplier
+	if mult <= 0 {
+		mult = 2
+	}
+
+	d := float64(p.InitialBackoff)
+	for range attempt - 1 {
+		d *= mult
+		if p.MaxBackoff > 0 && d >= float64(p.MaxBackoff) {
+			break
+		}
+	}
+
+	if p.MaxBackoff > 0 {
+		d = min(d, float64(p.MaxBackoff))
+	}
+
+	if jitter := min(max(p.Jitter, 0), 1); jitter > 0 {
+		d *= 1 + jitter*(2*rand.Float64()-1)
+	}
+
+	return time.Duration(d)
+}
+
+// call stage callback retrying it according to `Retry` option
+func (st *stage) callRetry(ctx context.Context, itemSpan SpanID, cb func() error) error {
+	policy := st.cfg.retry
+	for attempt := 1; ; attempt++ {
+		err := st.call(itemSpan, cb)
+		if err == nil || policy == nil || !policy.canRetry(attempt, err) {
+			return err
+		}
+
+		st.stats.retried()
+
+		if !sleep(ctx, policy.backoff(attempt)) {
+			return err // pipeline was cancelled, report the last error
+		}
+	}
+}
+
+// returns `false` if `ctx` was cancelled
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := GetClock(ctx).NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,140 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+var errTransient = errors.New("transient")
+
+func TestRetry(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	attempts := atomic.Int32{}
+
+	seq := sequence(ctx, 0, 1)
+	res, cherr := pl.TransformErr(ctx, 1, seq, func(x int) (int, error) {
+		if attempts.Add(1) < 3 {
+			return 0, errTransient
+		}
+		return x + 1, nil
+	}, pl.Retry(pl.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+
+	assert.Equal(t, 1, pipelinetest.CheckRead(t, res))
+	pipelinetest.CheckPending(t, cherr)
+	assert.Equal(t, int32(3), attempts.Load())
+
+	st := pl.Stats(ctx)[1]
+	assert.Equal(t, int64(2), st.Retries)
+	assert.Equal(t, int64(0), st.Errors)
+}
+
+func TestRetry_MaxAttempts(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	attempts := atomic.Int32{}
+
+	seq := sequence(ctx, 0, 1)
+	finished, cherr := pl.ProcessErr(ctx, 1, seq, func(x int) error {
+		attempts.Add(1)
+		return errTransient
+	}, pl.Retry(pl.RetryPolicy{MaxAttempts: 4}))
+
+	assert.Equal(t, errTransient, pipelinetest.CheckRead(t, cherr))
+	pipelinetest.CheckPending(t, finished)
+	assert.Equal(t, int32(4), attempts.Load())
+}
+
+func TestRetry_Retryable(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	attempts := atomic.Int32{}
+
+	seq := sequence(ctx, 0, 1)
+	_, cherr := pl.ProcessErr(ctx, 1, seq, func(x int) error {
+		if attempts.Add(1) == 1 {
+			return errTransient
+		}
+		return errTest
+	}, pl.Retry(pl.RetryPolicy{
+		MaxAttempts: 10,
+		Retryable: func(err error) bool {
+			return errors.Is(err, errTransient)
+		},
+	}))
+
+	assert.Equal(t, errTest, pipelinetest.CheckRead(t, cherr))
+	assert.Equal(t, int32(2), attempts.Load())
+}
+
+func TestRetry_PanicIsNotRetried(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	attempts := atomic.Int32{}
+
+	seq := sequence(ctx, 0, 1)
+	_, cherr := pl.TransformErr(ctx, 1, seq, func(x int) (int, error) {
+		attempts.Add(1)
+		panic("boom")
+	}, pl.Retry(pl.RetryPolicy{MaxAttempts: 10}))
+
+	var perr *pl.PanicError
+	assert.ErrorAs(t, pipelinetest.CheckRead(t, cherr), &perr)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestRetry_BackoffIsCancelled(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	failed := pl.NewSignal()
+
+	seq := sequence(ctx, 0, 1)
+	_, cherr := pl.ProcessErr(ctx, 1, seq, func(x int) error {
+		failed.Set()
+		return errTransient
+	}, pl.Retry(pl.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Hour}))
+
+	pipelinetest.CheckSignaled(t, failed)
+	pipelinetest.CheckShutdown(t, cancel)
+
+	// the last error is reported
+	assert.Equal(t, errTransient, pipelinetest.CheckRead(t, cherr))
+}
+
+func TestRetry_Backoff(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	var times []time.Time
+
+	seq := sequence(ctx, 0, 1)
+	_, cherr := pl.ProcessErr(ctx, 1, seq, func(x int) error {
+		times = append(times, time.Now())
+		return errTransient
+	}, pl.Retry(pl.RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 2 * time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Jitter:         0.1,
+	}))
+
+	assert.Equal(t, errTransient, pipelinetest.CheckRead(t, cherr))
+
+	if assert.Len(t, times, 4) {
+		// 2ms, 4ms, 5ms (limited) +- 10%
+		assert.GreaterOrEqual(t, times[1].Sub(times[0]), 1800*time.Microsecond)
+		assert.GreaterOrEqual(t, times[2].Sub(times[1]), 3600*time.Microsecond)
+		assert.GreaterOrEqual(t, times[3].Sub(times[2]), 4500*time.Microsecond)
+	}
+}
@@ -4,33 +4,25 @@ import "context"
 
 func Run(ctx context.Context, cb func()) Signal {
 	finished := NewSignal()
-	wg := getWaitGroup(ctx)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	spawn(ctx, newOrigin("Run"), func() {
 		defer finished.Set()
 		cb()
-	}()
+	})
 	return finished.Chan()
 }
 
 func RunErr(ctx context.Context, cb func() error) (Signal, Oneshot[error]) {
 	finished := NewSignal()
-	cherr := NewOneshot[error]()
-
-	wg := getWaitGroup(ctx)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	cherr := spawnErr(ctx, newOrigin("RunErr"), func() error {
 		err := cb()
 		if err != nil {
 			// note: `finished` is not triggered in this case
-			cherr.Write(err)
-			return
+			return err
 		}
 
 		finished.Set()
-	}()
+		return nil
+	})
 
-	return finished.Chan(), cherr.Chan()
+	return finished.Chan(), cherr
 }
@@ -5,26 +5,27 @@ import (
 	"testing"
 
 	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestRun(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	processed := pl.NewSignal()
 	finished := pl.Run(ctx, func() {
 		processed.Wait()
 	})
 
-	checkPending(t, finished)
+	pipelinetest.CheckPending(t, finished)
 	processed.Set()
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 }
 
 func TestRunErr(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	processed := pl.NewSignal()
 	finished, cherr := pl.RunErr(ctx, func() error {
@@ -32,16 +33,16 @@ func TestRunErr(t *testing.T) {
 		return nil
 	})
 
-	checkPending(t, finished)
+	pipelinetest.CheckPending(t, finished)
 	processed.Set()
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 
-	checkPending(t, cherr) // no errors
+	pipelinetest.CheckPending(t, cherr) // no errors
 }
 
 func TestRunErr_PropagateError(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	doFail := pl.NewSignal()
 	finished, cherr := pl.RunErr(ctx, func() error {
@@ -49,11 +50,11 @@ func TestRunErr_PropagateError(t *testing.T) {
 		return errTest
 	})
 
-	checkPending(t, finished)
+	pipelinetest.CheckPending(t, finished)
 	doFail.Set()
 
-	err := checkRead(t, cherr)
+	err := pipelinetest.CheckRead(t, cherr)
 	assert.Equal(t, err, errTest)
 
-	checkPending(t, finished) // don't trigger `finished` on error
+	pipelinetest.CheckPending(t, finished) // don't trigger `finished` on error
 }
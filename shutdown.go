@@ -0,0 +1,224 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"runtime/pprof"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrShutdownTimeout = errors.New("shutdown timeout")
+
+// goroutine that hasn't exited on `CancelTimeout`
+type GoroutineInfo struct {
+	API   string // function that spawned the goroutine, e.g. "Go" or "Transform"
+	Stage string // stage name, empty for `Go`, `Run`, etc.
+	Site  string // "file:line" of the code that called `API`
+	Stack string // current stack of the goroutine
+}
+
+// returned by `CancelTimeout`, it wraps `ErrShutdownTimeout`
+type ShutdownTimeoutError struct {
+	Goroutines []GoroutineInfo
+}
+
+func (e *ShutdownTimeoutError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%v: %d goroutines are still running", ErrShutdownTimeout, len(e.Goroutines))
+	for _, g := range e.Goroutines {
+		sb.WriteString("\n\n")
+		sb.WriteString(g.API)
+		if g.Stage != "" {
+			fmt.Fprintf(&sb, " %q", g.Stage)
+		}
+		fmt.Fprintf(&sb, " spawned at %s\n%s", g.Site, g.Stack)
+	}
+	return sb.String()
+}
+
+func (e *ShutdownTimeoutError) Unwrap() error {
+	return ErrShutdownTimeout
+}
+
+// cancel the pipeline created by `NewPipeline` and wait its goroutines at most `timeout`
+//
+// returns `*ShutdownTimeoutError` with goroutines that are still running after `timeout`,
+// they are not waited anymore; returns `nil` if context was created without `NewPipeline`
+func CancelTimeout(ctx context.Context, timeout time.Duration) error {
+	drain, _ := ctx.Value(drainKey).(*drainState)
+	if drain == nil {
+		return nil
+	}
+
+	drain.cancel()
+
+	wg := getWaitGroup(ctx)
+	finished := NewSignal()
+	go func() {
+		wg.Wait()
+		finished.Set()
+	}()
+
+	if finished.TryWaitClock(GetClock(ctx), timeout) {
+		return nil
+	}
+
+	running := wg.tasks.running()
+	if len(running) == 0 {
+		return nil // all goroutines have exited just now
+	}
+	return &ShutdownTimeoutError{Goroutines: running}
+}
+
+// where and by whom a goroutine was spawned
+type origin struct {
+	api   string
+	stage string
+	site  string
+}
+
+func newOrigin(api string) origin {
+	return origin{api: api, site: callSite()}
+}
+
+// running goroutines of a pipeline
+type taskRegistry struct {
+	mu    sync.Mutex
+	tasks map[uint64]origin // by task id, see `taskLabel`
+}
+
+func newTaskRegistry() *taskRegistry {
+	return &taskRegistry{tasks: make(map[uint64]origin)}
+}
+
+// profiler label of a goroutine registered as a task, its value is the task id;
+// stack is looked up by it only when it's needed, so tasks are cheap to register
+const taskLabel = "pipeline-task"
+
+var lastTaskID atomic.Uint64 // unique among all pipelines, since they share goroutine labels
+
+// register calling goroutine, returns function that unregisters it
+func (r *taskRegistry) add(ctx context.Context, o origin) func() {
+	if r == nil {
+		return func() {}
+	}
+
+	id := lastTaskID.Add(1)
+	pprof.SetGoroutineLabels(pprof.WithLabels(ctx, pprof.Labels(taskLabel, strconv.FormatUint(id, 10))))
+
+	r.mu.Lock()
+	r.tasks[id] = o
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		delete(r.tasks, id)
+		r.mu.Unlock()
+	}
+}
+
+func (r *taskRegistry) running() []GoroutineInfo {
+	r.mu.Lock()
+	tasks := make(map[uint64]origin, len(r.tasks))
+	for id, o := range r.tasks {
+		tasks[id] = o
+	}
+	r.mu.Unlock()
+
+	stacks := taskStacks()
+
+	var res []GoroutineInfo
+	for id, o := range tasks {
+		stack, ok := stacks[id]
+		if !ok {
+			continue // exited meanwhile
+		}
+
+		res = append(res, GoroutineInfo{
+			API:   o.api,
+			Stage: o.stage,
+			Site:  o.site,
+			Stack: stack,
+		})
+	}
+
+	slices.SortFunc(res, func(a, b GoroutineInfo) int {
+		return strings.Compare(a.Site+a.Stage, b.Site+b.Stage)
+	})
+	return res
+}
+
+var pkgPrefix = reflect.TypeOf(origin{}).PkgPath() + "."
+
+// location of the first caller outside of this package
+func callSite() string {
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, pkgPrefix) {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// stacks of the running tasks by id, they are parsed from the goroutine profile:
+//
+//	1 @ 0x43a0f6 0x4069c5
+//	# labels: {"pipeline-task":"42"}
+//	#	0x4f1e2c	main.foo+0x2c	/path/main.go:10
+//	#	0x4069c4	main.main+0x24	/path/main.go:20
+func taskStacks() map[uint64]string {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return nil
+	}
+
+	res := make(map[uint64]string)
+	for _, record := range strings.Split(buf.String(), "\n\n") {
+		var (
+			id     uint64
+			found  bool
+			frames []string
+		)
+
+		for _, line := range strings.Split(record, "\n") {
+			if labels, ok := strings.CutPrefix(line, "# labels: "); ok {
+				id, found = parseTaskLabel(labels)
+			} else if frame, ok := strings.CutPrefix(line, "#\t"); ok {
+				_, frame, _ = strings.Cut(frame, "\t") // skip address
+				frames = append(frames, frame)
+			}
+		}
+
+		if found {
+			res[id] = strings.Join(frames, "\n")
+		}
+	}
+	return res
+}
+
+func parseTaskLabel(labels string) (uint64, bool) {
+	_, value, ok := strings.Cut(labels, strconv.Quote(taskLabel)+`:"`)
+	if !ok {
+		return 0, false
+	}
+
+	value, _, _ = strings.Cut(value, `"`)
+	id, err := strconv.ParseUint(value, 10, 64)
+	return id, err == nil
+}
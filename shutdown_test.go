@@ -0,0 +1,82 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancelTimeout(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 10)
+	res := pl.Transform(ctx, 2, seq, func(x int) int { return x })
+	_ = pl.Process(ctx, 1, res, func(int) {})
+
+	pipelinetest.WithTimeout(t, "cancel", func() {
+		assert.NoError(t, pl.CancelTimeout(ctx, time.Second))
+	})
+}
+
+func TestCancelTimeout_Leak(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	release := pl.NewSignal()
+	defer func() {
+		release.Set()
+		pipelinetest.CheckShutdown(t, cancel)
+	}()
+
+	input := make(chan int, 1)
+	input <- 1
+	started := pl.NewSignal()
+	_, file, processLine, _ := runtime.Caller(0)
+	_ = pl.Process(ctx, 1, input, func(int) {
+		started.Set()
+		release.Wait() // ignores cancellation
+	}, pl.Name("stuck"))
+	pipelinetest.CheckSignaled(t, started)
+
+	_, _, goLine, _ := runtime.Caller(0)
+	pl.Go(ctx, func() {
+		release.Wait()
+	})
+
+	var err error
+	pipelinetest.WithTimeout(t, "cancel", func() {
+		err = pl.CancelTimeout(ctx, 10*time.Millisecond)
+	})
+	assert.ErrorIs(t, err, pl.ErrShutdownTimeout)
+
+	var terr *pl.ShutdownTimeoutError
+	if !errors.As(err, &terr) || !assert.Len(t, terr.Goroutines, 3) {
+		return
+	}
+
+	// sorted by call site: stuck worker and the stage goroutine that waits for it
+	stuck, goroutine := terr.Goroutines[0], terr.Goroutines[2]
+	assert.Equal(t, stuck.Site, terr.Goroutines[1].Site)
+
+	assert.Equal(t, "Process", stuck.API)
+	assert.Equal(t, "stuck", stuck.Stage)
+	assert.Equal(t, fmt.Sprintf("%s:%d", file, processLine+1), stuck.Site)
+	assert.Contains(t, stuck.Stack+terr.Goroutines[1].Stack, "TestCancelTimeout_Leak")
+
+	assert.Equal(t, "Go", goroutine.API)
+	assert.Equal(t, "", goroutine.Stage)
+	assert.Equal(t, fmt.Sprintf("%s:%d", file, goLine+1), goroutine.Site)
+
+	assert.Contains(t, err.Error(), `Process "stuck" spawned at`)
+}
+
+func TestCancelTimeout_NoPipeline(t *testing.T) {
+	assert.NoError(t, pl.CancelTimeout(context.Background(), 0))
+}
@@ -14,7 +14,13 @@ func (sig Signal) Wait() {
 	<-sig
 }
 
+// wait at most `d`, returns `false` on timeout
 func (sig Signal) TryWait(d time.Duration) bool {
+	return sig.TryWaitClock(SystemClock(), d)
+}
+
+// same as `TryWait`, but timeout is measured by `clock`, e.g. `GetClock(ctx)` of a pipeline
+func (sig Signal) TryWaitClock(clock Clock, d time.Duration) bool {
 	if d == 0 {
 		// don't allocate if no timeout
 		select {
@@ -25,10 +31,13 @@ func (sig Signal) TryWait(d time.Duration) bool {
 		}
 	}
 
+	timer := clock.NewTimer(d)
+	defer timer.Stop()
+
 	select {
 	case <-sig:
 		return true
-	case <-time.After(d):
+	case <-timer.C():
 		return false
 	}
 }
@@ -57,3 +66,7 @@ func (sig SignalMut) Wait() {
 func (sig SignalMut) TryWait(d time.Duration) bool {
 	return sig.Chan().TryWait(d)
 }
+
+func (sig SignalMut) TryWaitClock(clock Clock, d time.Duration) bool {
+	return sig.Chan().TryWaitClock(clock, d)
+}
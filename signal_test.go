@@ -5,45 +5,20 @@ import (
 	"time"
 
 	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
 	"github.com/stretchr/testify/assert"
 )
 
-func checkPending[T any](t *testing.T, ch <-chan T) {
-	t.Helper()
-
-	select {
-	case val, ok := <-ch:
-		if !ok {
-			t.Fatalf("channel was closed")
-		}
-
-		t.Fatalf("channel is not in a pending state (received %v)", val)
-	default:
-		// success
-	}
-}
-
-func checkSignaled[T any](t *testing.T, ch <-chan T) {
-	t.Helper()
-
-	select {
-	case <-ch:
-		// success
-	case <-time.After(time.Second):
-		t.Fatalf("channel is not in a signaled state")
-	}
-}
-
 func TestSignal(t *testing.T) {
 	mut := pl.NewSignal()
 	sig := mut.Chan()
 
-	checkPending(t, mut)
-	checkPending(t, sig)
+	pipelinetest.CheckPending(t, mut)
+	pipelinetest.CheckPending(t, sig)
 
 	mut.Set()
-	checkSignaled(t, mut)
-	checkSignaled(t, sig)
+	pipelinetest.CheckSignaled(t, mut)
+	pipelinetest.CheckSignaled(t, sig)
 }
 
 func TestSignalWait(t *testing.T) {
@@ -55,13 +30,13 @@ func TestSignalWait(t *testing.T) {
 		finished.Set()
 	}(mut.Chan())
 
-	checkPending(t, finished)
+	pipelinetest.CheckPending(t, finished)
 	mut.Set()
-	checkSignaled(t, finished)
+	pipelinetest.CheckSignaled(t, finished)
 }
 
 func TestSignalTryWait(t *testing.T) {
-	withTimeout(t, "wait signaled", func() {
+	pipelinetest.WithTimeout(t, "wait signaled", func() {
 		sig := pl.NewSignal()
 		sig.Set()
 
@@ -69,7 +44,7 @@ func TestSignalTryWait(t *testing.T) {
 		assert.True(t, r)
 	})
 
-	withTimeout(t, "wait for signal timeout", func() {
+	pipelinetest.WithTimeout(t, "wait for signal timeout", func() {
 		sig := pl.NewSignal()
 		r := sig.TryWait(10 * time.Millisecond)
 		assert.False(t, r)
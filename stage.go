@@ -0,0 +1,449 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// running stage of a pipeline, e.g. a single `Transform` call
+type stage struct {
+	api   string       // pipeline function name, e.g. "Transform"
+	name  string       // stage name, see `Name`
+	cfg   stageConfig  // stage options
+	stats *stageStats  // nil if context was created without `NewPipeline`
+	tr    *tracing     // nil if context has no tracer
+	span  SpanID       // stage span if traced
+	errs  *errorBudget // nil if stage is not in `ContinueOnError` mode
+	site  string       // code that created the stage, see `CancelTimeout`
+	clock Clock
+	links *linkRegistry // nil if items don't pass metadata between stages
+
+	unlink []func() // unregister links of stage outputs, e.g. if they are not closed on error
+
+	watermarked bool              // input items carry watermarks, see `AssignWatermarks`
+	wm          *watermarkTracker // set if stage reorders items, see `startWorkers`
+}
+
+func newStage(ctx context.Context, api string, opts []Option) *stage {
+	cfg := newStageConfig(ctx, opts)
+
+	st := &stage{
+		api:   api,
+		name:  cfg.name,
+		cfg:   cfg,
+		stats: registerStage(ctx, api, cfg.name),
+		tr:    getTracing(ctx),
+		site:  callSite(),
+		clock: GetClock(ctx),
+		links: getLinks(ctx),
+	}
+
+	if st.stats != nil {
+		st.name = st.stats.name // default name is assigned by registry
+	} else if st.name == "" {
+		st.name = api
+	}
+
+	if st.tr != nil {
+		st.span = st.tr.newSpan()
+		st.trace(TraceEvent{
+			Kind:  StageStart,
+			Span:  st.span,
+			Stage: st.name,
+			API:   st.api,
+		})
+	}
+
+	return st
+}
+
+// emit trace event, stage must be traced
+func (st *stage) trace(ev TraceEvent) {
+	ev.Time = st.clock.Now()
+	st.tr.emit(ev)
+}
+
+func (st *stage) origin() origin {
+	return origin{api: st.api, stage: st.name, site: st.site}
+}
+
+// must be called once when all stage goroutines are finished
+func (st *stage) end() {
+	for _, unlink := range st.unlink {
+		unlink()
+	}
+
+	if st.tr != nil {
+		st.trace(TraceEvent{
+			Kind:  StageEnd,
+			Span:  st.span,
+			Stage: st.name,
+			API:   st.api,
+		})
+	}
+}
+
+// spawn `threads` workers that read `in` until it is closed or `handle` returns `false`
+//
+// number of workers can be changed later by `Pool` and `Autoscale` options,
+// items are passed to `handle` according to `RateLimit` option
+func startWorkers[T any](ctx context.Context, st *stage, threads int, in *input[T], handle func(item[T]) bool) *workerGroup {
+	if st.watermarked {
+		st.wm = newWatermarkTracker()
+	}
+
+	limit := stageLimiter[T](st)
+
+	var group *workerGroup
+	group = newWorkerGroup(func() {
+		st.stats.workerStarted()
+		spawn(ctx, st.origin(), func() {
+			defer st.stats.workerStopped()
+
+			for {
+				retire, wake := group.retire()
+				if retire {
+					return
+				}
+
+				group.waiting.Add(1)
+				it, ok, woken := in.readOrStop(ctx, wake)
+				group.waiting.Add(-1)
+
+				if woken {
+					continue // group was downsized, check whether to retire
+				}
+
+				if ok && limit != nil {
+					err := limit.wait(ctx, st, it.val)
+					if perr, isPanic := err.(*PanicError); isPanic {
+						// key function panicked, the item is skipped
+						st.fail()
+						reportItemPanic(ctx, it.val, perr)
+						st.wm.done(it.seq)
+						continue
+					}
+					ok = err == nil
+				}
+
+				if ok {
+					ok = handle(it)
+					st.wm.done(it.seq)
+				}
+
+				if !ok {
+					group.exit()
+					return
+				}
+			}
+		})
+	})
+
+	st.startGroup(ctx, group, threads, in.backlog)
+	return group
+}
+
+// spawn initial workers of `group`, their number is set by `threads`, `Pool` and `Autoscale`
+// options; `backlog` is the number of items that wait for a worker, see `Autoscale`
+func (st *stage) startGroup(ctx context.Context, group *workerGroup, threads int, backlog func() int) {
+	if auto := st.cfg.autoscale; auto != nil {
+		threads = min(max(threads, auto.min), auto.max)
+	}
+
+	group.resize(threads)
+
+	if st.cfg.pool != nil {
+		st.cfg.pool.attach(group)
+	}
+
+	if auto := st.cfg.autoscale; auto != nil {
+		group.autoscale(ctx, st.origin(), auto, backlog)
+	}
+}
+
+// call stage callback for an item tracking its latency and tracing its call span
+//
+// panic is returned as `*PanicError`, so non-fallible callbacks fail only with it
+func (st *stage) call(itemSpan SpanID, cb func() error) (err error) {
+	if st.tr != nil {
+		span := st.tr.newSpan()
+		ev := TraceEvent{
+			Kind:   CallStart,
+			Span:   span,
+			Parent: st.span,
+			Item:   itemSpan,
+			Stage:  st.name,
+			API:    st.api,
+		}
+		st.trace(ev)
+
+		defer func() {
+			ev.Kind = CallEnd
+			ev.Err = err
+			st.trace(ev)
+		}()
+	}
+
+	if st.stats == nil {
+		return st.safeCall(cb)
+	}
+
+	st.stats.inFlight.Add(1)
+	defer st.stats.inFlight.Add(-1)
+
+	start := st.clock.Now()
+	err = st.safeCall(cb)
+	st.stats.addLatency(st.clock.Now().Sub(start))
+
+	return err
+}
+
+func (st *stage) safeCall(cb func() error) (err error) {
+	if perr := catchPanic(st.api, func() { err = cb() }); perr != nil {
+		return perr
+	}
+	return err
+}
+
+func (st *stage) fail() {
+	if st.stats != nil {
+		st.stats.errors.Add(1)
+	}
+}
+
+// item passed between stages along with its metadata
+type item[T any] struct {
+	val  T
+	span SpanID    // item span assigned by `Generate`, 0 if not traced
+	wm   time.Time // watermark of the stream at this item, zero if unknown
+	seq  uint64    // order of the item in the stage input if stage tracks watermarks
+}
+
+// create a new item in the source stage
+func newItem[T any](st *stage, val T) item[T] {
+	it := item[T]{val: val}
+	if st.tr != nil {
+		it.span = st.tr.newSpan()
+		st.trace(TraceEvent{
+			Kind:   ItemStart,
+			Span:   it.span,
+			Parent: st.span,
+			Stage:  st.name,
+			API:    st.api,
+		})
+	}
+	return it
+}
+
+// stage input channel that receives item metadata from upstream stages
+type input[T any] struct {
+	st   *stage
+	ch   <-chan T
+	link *link[T] // nil if upstream is not a stage
+}
+
+func newInput[T any](st *stage, in <-chan T) *input[T] {
+	input := &input[T]{st: st, ch: in}
+	if l := attachLink(st.links, in); l != nil {
+		input.link = l
+		st.watermarked = st.watermarked || l.watermarked
+	}
+	return input
+}
+
+// `Read` that tracks stage stats
+func (in *input[T]) read(ctx context.Context) (item[T], bool) {
+	it, ok, _ := in.readUntil(ctx, nil)
+	return it, ok
+}
+
+// same as `read`, but stops waiting when `timeout` is triggered (`expired` is set then)
+func (in *input[T]) readUntil(ctx context.Context, timeout <-chan time.Time) (it item[T], ok bool, expired bool) {
+	return in.readWait(ctx, timeout, nil)
+}
+
+// same as `read`, but stops waiting when `stop` is closed (`stopped` is set then)
+func (in *input[T]) readOrStop(ctx context.Context, stop <-chan struct{}) (it item[T], ok bool, stopped bool) {
+	return in.readWait(ctx, nil, stop)
+}
+
+func (in *input[T]) readWait(ctx context.Context, timeout <-chan time.Time, stop <-chan struct{}) (it item[T], ok bool, interrupted bool) {
+	if in.st.errs != nil {
+		defer func() {
+			if ok {
+				in.st.errs.read.Add(1)
+			}
+		}()
+	}
+
+	if in.st.stats == nil {
+		return in.readItem(ctx, timeout, stop)
+	}
+
+	start := in.st.clock.Now()
+	it, ok, interrupted = in.readItem(ctx, timeout, stop)
+	in.st.stats.readBlocked.Add(int64(in.st.clock.Now().Sub(start)))
+
+	if ok {
+		in.st.stats.read.Add(1)
+	}
+	return
+}
+
+// number of items waiting in the input channel
+func (in *input[T]) backlog() int {
+	return len(in.ch)
+}
+
+func (in *input[T]) readItem(ctx context.Context, timeout <-chan time.Time, stop <-chan struct{}) (item[T], bool, bool) {
+	l := in.link
+	if l != nil {
+		// hold the link, so concurrent readers don't swap metadata of their values
+		select {
+		case l.reading <- struct{}{}:
+		case <-timeout:
+			return item[T]{}, false, true
+		case <-stop:
+			return item[T]{}, false, true
+		case <-ctx.Done():
+			return item[T]{}, false, false
+		}
+		defer func() { <-l.reading }()
+	}
+
+	select {
+	case v, ok := <-in.ch:
+		if !ok {
+			return item[T]{}, false, false
+		}
+
+		it := item[T]{val: v}
+		if l != nil {
+			it = l.pop(v)
+		}
+		if wm := in.st.wm; wm != nil {
+			// the link is still held, so items are registered in the input order
+			it.seq = wm.add(it.wm)
+		}
+		return it, true, false
+
+	case <-timeout:
+		return item[T]{}, false, true
+
+	case <-stop:
+		return item[T]{}, false, true
+
+	case <-ctx.Done():
+		return item[T]{}, false, false
+	}
+}
+
+// stage output channel that passes item metadata to downstream stages
+type output[T any] struct {
+	st   *stage
+	ch   chan T
+	link *link[T] // nil if context has no link registry or items have no metadata
+}
+
+func newOutput[T any](st *stage) *output[T] {
+	return newOutputSize[T](st, st.cfg.buffer)
+}
+
+func newOutputSize[T any](st *stage, size int) *output[T] {
+	out := &output[T]{
+		st: st,
+		ch: make(chan T, size),
+	}
+	if st.tr == nil && !st.watermarked {
+		return out // items have no metadata to pass
+	}
+
+	out.link = registerLink(st.links, out.ch, st.watermarked)
+	if out.link != nil {
+		st.unlink = append(st.unlink, func() { unregisterLink(st.links, out.ch) })
+	}
+	return out
+}
+
+// `Write` that tracks stage stats
+func (out *output[T]) write(ctx context.Context, it item[T]) bool {
+	if out.st.wm != nil {
+		it.wm = out.st.wm.output()
+	}
+
+	if out.st.stats == nil {
+		return out.writeItem(ctx, it)
+	}
+
+	start := out.st.clock.Now()
+	ok := out.writeItem(ctx, it)
+	out.st.stats.writeBlocked.Add(int64(out.st.clock.Now().Sub(start)))
+
+	if ok {
+		out.st.stats.written.Add(1)
+	}
+	return ok
+}
+
+// write without blocking, returns `false` if channel is full
+func (out *output[T]) tryWrite(it item[T]) bool {
+	if out.st.wm != nil {
+		it.wm = out.st.wm.output()
+	}
+
+	var ok bool
+	if l := out.link; l == nil {
+		ok = trySend(out.ch, it.val)
+	} else {
+		select {
+		case l.writing <- struct{}{}:
+			queued := l.push(it)
+			ok = trySend(out.ch, it.val)
+			l.pushed(ok, queued)
+			<-l.writing
+		default:
+		}
+	}
+
+	if ok && out.st.stats != nil {
+		out.st.stats.written.Add(1)
+	}
+	return ok
+}
+
+func trySend[T any](ch chan<- T, v T) bool {
+	select {
+	case ch <- v:
+		return true
+	default:
+		return false
+	}
+}
+
+func (out *output[T]) writeItem(ctx context.Context, it item[T]) bool {
+	l := out.link
+	if l == nil {
+		return Write(ctx, out.ch, it.val)
+	}
+
+	// hold the link, so metadata is queued in the order of values
+	select {
+	case l.writing <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+	defer func() { <-l.writing }()
+
+	queued := l.push(it)
+	ok := Write(ctx, out.ch, it.val)
+	l.pushed(ok, queued)
+	return ok
+}
+
+func (out *output[T]) close() {
+	close(out.ch)
+
+	if out.link != nil {
+		unregisterLink(out.st.links, out.ch)
+	}
+}
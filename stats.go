@@ -0,0 +1,187 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// snapshot of stage runtime statistics, see `Stats`
+type StageStats struct {
+	Name string // stage name set by `Name` option, "<API>#<index>" by default
+	API  string // pipeline function that created the stage, e.g. "Transform"
+
+	Workers  int // number of running workers
+	InFlight int // number of workers that are running the callback now
+
+	Read    int64 // items read from the input channel
+	Written int64 // items written to the output channel
+	Errors  int64 // failed items, including recovered panics
+	Retries int64 // failed attempts that were retried, see `Retry`
+
+	Latency LatencyStats // callback duration
+
+	ReadBlocked  time.Duration // total time workers waited for input
+	WriteBlocked time.Duration // total time workers waited for output to be read
+}
+
+// callback latency percentiles, calculated over the recent calls
+type LatencyStats struct {
+	Count int64 // total number of calls
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// set stage name for the stages created with returned context
+func WithStageName(ctx context.Context, name string) context.Context {
+	return WithOptions(ctx, Name(name))
+}
+
+// return statistics of all stages in the order of creation
+//
+// returns `nil` if context was created without `NewPipeline`
+func Stats(ctx context.Context) []StageStats {
+	reg, _ := ctx.Value(statsKey).(*statsRegistry)
+	if reg == nil {
+		return nil
+	}
+
+	reg.mu.Lock()
+	stages := slices.Clone(reg.stages)
+	reg.mu.Unlock()
+
+	res := make([]StageStats, len(stages))
+	for k, st := range stages {
+		res[k] = st.snapshot()
+	}
+	return res
+}
+
+type statsRegistry struct {
+	mu     sync.Mutex
+	stages []*stageStats
+	names  map[string]int // number of stages with each name
+}
+
+// register stage stats, `name` is generated if empty
+func registerStage(ctx context.Context, api string, name string) *stageStats {
+	reg, _ := ctx.Value(statsKey).(*statsRegistry)
+	if reg == nil {
+		return nil
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if name == "" {
+		name = fmt.Sprintf("%s#%d", api, len(reg.stages))
+	} else if n := reg.names[name]; n > 0 {
+		// e.g. name is set for several stages by `WithOptions`
+		reg.names[name] = n + 1
+		name = fmt.Sprintf("%s#%d", name, n+1)
+	} else {
+		if reg.names == nil {
+			reg.names = make(map[string]int)
+		}
+		reg.names[name] = 1
+	}
+
+	st := &stageStats{name: name, api: api}
+	reg.stages = append(reg.stages, st)
+	return st
+}
+
+// number of recent calls used to calculate latency percentiles
+const latencyWindow = 1024
+
+type stageStats struct {
+	name string
+	api  string
+
+	workers  atomic.Int64
+	inFlight atomic.Int64
+
+	read    atomic.Int64
+	written atomic.Int64
+	errors  atomic.Int64
+	retries atomic.Int64
+
+	readBlocked  atomic.Int64 // nanoseconds
+	writeBlocked atomic.Int64 // nanoseconds
+
+	mu         sync.Mutex
+	calls      int64
+	latency    [latencyWindow]time.Duration // ring buffer of recent calls
+	maxLatency time.Duration
+}
+
+func (st *stageStats) workerStarted() {
+	if st != nil {
+		st.workers.Add(1)
+	}
+}
+
+func (st *stageStats) workerStopped() {
+	if st != nil {
+		st.workers.Add(-1)
+	}
+}
+
+func (st *stageStats) retried() {
+	if st != nil {
+		st.retries.Add(1)
+	}
+}
+
+func (st *stageStats) addLatency(d time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.latency[st.calls%latencyWindow] = d
+	st.calls += 1
+	st.maxLatency = max(st.maxLatency, d)
+}
+
+func (st *stageStats) snapshot() StageStats {
+	st.mu.Lock()
+	calls := st.calls
+	recent := slices.Clone(st.latency[:min(calls, latencyWindow)])
+	maxLatency := st.maxLatency
+	st.mu.Unlock()
+
+	slices.Sort(recent)
+
+	return StageStats{
+		Name:     st.name,
+		API:      st.api,
+		Workers:  int(st.workers.Load()),
+		InFlight: int(st.inFlight.Load()),
+		Read:     st.read.Load(),
+		Written:  st.written.Load(),
+		Errors:   st.errors.Load(),
+		Retries:  st.retries.Load(),
+		Latency: LatencyStats{
+			Count: calls,
+			P50:   percentile(recent, 50),
+			P90:   percentile(recent, 90),
+			P99:   percentile(recent, 99),
+			Max:   maxLatency,
+		},
+		ReadBlocked:  time.Duration(st.readBlocked.Load()),
+		WriteBlocked: time.Duration(st.writeBlocked.Load()),
+	}
+}
+
+// `sorted` must be sorted in ascending order
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	return sorted[(len(sorted)-1)*p/100]
+}
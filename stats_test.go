@@ -0,0 +1,117 @@
+package pipeline_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 10)
+	doubled := pl.Transform(pl.WithStageName(ctx, "double"), 1, seq, func(x int) int {
+		time.Sleep(time.Millisecond)
+		return x * 2
+	})
+
+	finished, _ := pl.ProcessErr(ctx, 1, doubled, func(x int) error {
+		if x == 18 {
+			return errTest
+		}
+		return nil
+	})
+
+	pipelinetest.CheckPending(t, finished)
+
+	pipelinetest.WithTimeout(t, "wait all processed", func() {
+		for {
+			stats := pl.Stats(ctx)
+			if stats[2].Errors == 1 && stats[1].Workers == 0 {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	stats := pl.Stats(ctx)
+	if !assert.Len(t, stats, 3) {
+		return
+	}
+
+	gen := stats[0]
+	assert.Equal(t, "Generate#0", gen.Name)
+	assert.Equal(t, int64(10), gen.Written)
+	assert.Equal(t, int64(1), gen.Latency.Count)
+
+	tr := stats[1]
+	assert.Equal(t, "double", tr.Name)
+	assert.Equal(t, "Transform", tr.API)
+	assert.Equal(t, 0, tr.InFlight)
+	assert.Equal(t, int64(10), tr.Read)
+	assert.Equal(t, int64(10), tr.Written)
+	assert.Equal(t, int64(0), tr.Errors)
+	assert.Equal(t, int64(10), tr.Latency.Count)
+	assert.GreaterOrEqual(t, tr.Latency.P50, time.Millisecond)
+	assert.GreaterOrEqual(t, tr.Latency.Max, tr.Latency.P99)
+	assert.GreaterOrEqual(t, tr.Latency.P99, tr.Latency.P90)
+	assert.GreaterOrEqual(t, tr.Latency.P90, tr.Latency.P50)
+
+	proc := stats[2]
+	assert.Equal(t, "ProcessErr#2", proc.Name)
+	assert.Equal(t, "ProcessErr", proc.API)
+	assert.Equal(t, int64(10), proc.Read)
+	assert.Equal(t, int64(0), proc.Written)
+	assert.Equal(t, int64(1), proc.Errors)
+	assert.Equal(t, 0, proc.Workers) // the only worker has failed
+	assert.Greater(t, proc.ReadBlocked, time.Duration(0))
+}
+
+func TestStats_InFlight(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan int)
+	started := pl.NewSignal()
+	passResult := pl.NewSignal()
+
+	res := pl.Transform(ctx, 4, input, func(x int) int {
+		started.Set()
+		passResult.Wait()
+		return x
+	})
+
+	pipelinetest.WithTimeout(t, "start processing", func() {
+		input <- 1
+		started.Wait()
+	})
+
+	st := pl.Stats(ctx)[0]
+	assert.Equal(t, "Transform", st.API)
+	assert.Equal(t, 4, st.Workers)
+	assert.Equal(t, 1, st.InFlight)
+
+	passResult.Set()
+	assert.Equal(t, 1, pipelinetest.CheckRead(t, res))
+
+	pipelinetest.WithTimeout(t, "wait callback finished", func() {
+		for pl.Stats(ctx)[0].InFlight != 0 {
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
+
+func TestStats_WithoutPipeline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	seq := sequence(ctx, 0, 10)
+	_ = pl.Transform(ctx, 1, seq, func(x int) int { return x })
+
+	assert.Nil(t, pl.Stats(ctx))
+}
@@ -0,0 +1,90 @@
+package pipeline
+
+import "context"
+
+// how `Tee` delivers items to a consumer, see `TeePolicies`
+type TeePolicy struct {
+	buffer int
+	drop   bool
+}
+
+// slow consumer blocks all others, output is buffered according to `Buffer` option
+func TeeBlock() TeePolicy {
+	return TeePolicy{buffer: -1}
+}
+
+// consumer has its own buffer of `size` items, it blocks others only when the buffer is full
+func TeeBuffer(size int) TeePolicy {
+	return TeePolicy{buffer: max(size, 0)}
+}
+
+// items are dropped for the consumer when its buffer of `size` items is full,
+// so it never blocks others
+func TeeDrop(size int) TeePolicy {
+	return TeePolicy{buffer: max(size, 0), drop: true}
+}
+
+// set `Tee` delivery policy of each output in order, outputs without policy use `TeeBlock`
+func TeePolicies(policies ...TeePolicy) Option {
+	return func(cfg *stageConfig) {
+		cfg.tee = policies
+	}
+}
+
+// deliver every item of `in` to each of `n` returned channels
+//
+// items are written to outputs one by one, so by default the slowest consumer
+// limits all of them, see `TeePolicies`; all outputs are closed when `in` is closed
+// or the pipeline is cancelled
+func Tee[T any](ctx context.Context, in <-chan T, n int, opts ...Option) []<-chan T {
+	st := newStage(ctx, "Tee", opts)
+	input := newInput(st, in)
+
+	outs := make([]*output[T], max(n, 0))
+	drop := make([]bool, len(outs))
+	res := make([]<-chan T, len(outs))
+	for k := range outs {
+		policy := TeeBlock()
+		if k < len(st.cfg.tee) {
+			policy = st.cfg.tee[k]
+		}
+
+		size := policy.buffer
+		if size < 0 {
+			size = st.cfg.buffer
+		}
+
+		outs[k] = newOutputSize[T](st, size)
+		drop[k] = policy.drop
+		res[k] = outs[k].ch
+	}
+
+	spawn(ctx, st.origin(), func() {
+		defer st.end()
+		defer func() {
+			for _, out := range outs {
+				out.close()
+			}
+		}()
+
+		for {
+			it, ok := input.read(ctx)
+			if !ok {
+				return
+			}
+
+			for k, out := range outs {
+				if drop[k] {
+					out.tryWrite(it)
+					continue
+				}
+
+				if !out.write(ctx, it) {
+					return
+				}
+			}
+		}
+	})
+
+	return res
+}
@@ -0,0 +1,93 @@
+package pipeline_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTee(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	outs := pl.Tee(ctx, sequence(ctx, 0, 5), 3)
+	assert.Len(t, outs, 3)
+
+	var wg sync.WaitGroup
+	res := make([][]int, len(outs))
+	for k, out := range outs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := range out {
+				res[k] = append(res[k], v)
+			}
+		}()
+	}
+
+	pipelinetest.WithTimeout(t, "read all outputs", wg.Wait)
+	for _, r := range res {
+		assert.Equal(t, []int{0, 1, 2, 3, 4}, r)
+	}
+}
+
+func TestTee_Block(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	outs := pl.Tee(ctx, sequence(ctx, 0, 5), 2)
+
+	assert.Equal(t, 0, pipelinetest.CheckRead(t, outs[0]))
+	assert.Equal(t, 0, pipelinetest.CheckRead(t, outs[1]))
+
+	// the second consumer doesn't read, so the first one is blocked
+	pipelinetest.CheckRead(t, outs[0])
+	pipelinetest.CheckPending(t, outs[0])
+}
+
+func TestTee_Buffer(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	outs := pl.Tee(ctx, sequence(ctx, 0, 10), 2, pl.TeePolicies(pl.TeeBlock(), pl.TeeBuffer(3)))
+
+	// the second consumer has its own buffer
+	for k := range 4 {
+		assert.Equal(t, k, pipelinetest.CheckRead(t, outs[0]))
+	}
+	pipelinetest.CheckPending(t, outs[0])
+
+	assert.Equal(t, 3, len(outs[1]))
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, []int{
+		pipelinetest.CheckRead(t, outs[1]), pipelinetest.CheckRead(t, outs[1]), pipelinetest.CheckRead(t, outs[1]),
+		pipelinetest.CheckRead(t, outs[1]), pipelinetest.CheckRead(t, outs[0]),
+	})
+}
+
+func TestTee_Drop(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	outs := pl.Tee(ctx, sequence(ctx, 0, 10), 2, pl.TeePolicies(pl.TeeBlock(), pl.TeeDrop(2)))
+
+	// the second consumer doesn't block
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, pipelinetest.ReadAll(t, outs[0]))
+
+	// only buffered items are left
+	assert.Equal(t, []int{0, 1}, pipelinetest.ReadAll(t, outs[1]))
+}
+
+func TestTee_Cancel(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	outs := pl.Tee(ctx, make(chan int), 3)
+	pipelinetest.CheckShutdown(t, cancel)
+
+	for _, out := range outs {
+		pipelinetest.ReadAll(t, out) // closed
+	}
+}
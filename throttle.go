@@ -0,0 +1,324 @@
+package pipeline
+
+import (
+	"container/heap"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// pass items from `in` at most `rate` items per second, allowing bursts of `burst` items
+func Throttle[T any](ctx context.Context, in <-chan T, rate float64, burst int, opts ...Option) <-chan T {
+	return throttle(ctx, "Throttle", in, append(slices.Clip(opts), RateLimit(rate, burst)))
+}
+
+// same as `Throttle`, but each key returned by `key` has its own quota
+//
+// items of the same key are passed in order, items that wait for the quota of their key
+// don't delay items of the other keys; at most `throttleBacklog` items wait at once,
+// then the input is not read until some of them are passed;
+// if `key` panics, the item is skipped and the panic is passed to the pipeline handler
+func ThrottleByKey[T any, K comparable](ctx context.Context, in <-chan T, rate float64, burst int, key func(T) K, opts ...Option) <-chan T {
+	checkRate(rate)
+
+	st := newStage(ctx, "ThrottleByKey", opts)
+	input := newInput(st, in)
+	out := newOutput[T](st)
+	l := newKeyedLimiter(rate, burst, key)
+
+	spawn(ctx, st.origin(), func() {
+		defer st.end()
+
+		waiting := &throttleQueue[T]{} // items that wait for the quota of their key
+		closed := false
+		var seq uint64
+
+		for {
+			now := st.clock.Now()
+			for waiting.Len() > 0 && !waiting.items[0].ready.After(now) {
+				w := heap.Pop(waiting).(throttledItem[T])
+				if !out.write(ctx, w.it) {
+					return
+				}
+			}
+
+			if closed && waiting.Len() == 0 {
+				out.close()
+				return
+			}
+
+			var timer Timer // triggered when the next waiting item is ready
+			var ready <-chan time.Time
+			if waiting.Len() > 0 {
+				timer = st.clock.NewTimer(waiting.items[0].ready.Sub(now))
+				ready = timer.C()
+			}
+
+			if closed || waiting.Len() >= throttleBacklog {
+				select {
+				case <-ready:
+				case <-ctx.Done():
+				}
+			} else if it, ok, expired := input.readUntil(ctx, ready); ok {
+				var k K
+				if err := st.safeCall(func() error { k = l.key(it.val); return nil }); err != nil {
+					st.fail()
+					reportItemPanic(ctx, it.val, err)
+				} else {
+					now := st.clock.Now()
+					heap.Push(waiting, throttledItem[T]{it: it, ready: now.Add(l.reserve(k, now)), seq: seq})
+					seq += 1
+				}
+			} else if !expired {
+				closed = true
+			}
+
+			if timer != nil {
+				timer.Stop()
+			}
+
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	})
+
+	return out.ch
+}
+
+// max number of items that wait for their quota in `ThrottleByKey`
+const throttleBacklog = 1024
+
+func throttle[T any](ctx context.Context, api string, in <-chan T, opts []Option) <-chan T {
+	st := newStage(ctx, api, opts)
+	input := newInput(st, in)
+	out := newOutput[T](st)
+
+	workers := startWorkers(ctx, st, 1, input, func(it item[T]) bool {
+		return out.write(ctx, it)
+	})
+
+	closeAfterAll(ctx, st, workers, nil, out)
+
+	return out.ch
+}
+
+// limit the rate of `Transform` or `Process` callback calls to `rate` calls
+// per second with bursts of `burst` calls, the quota is shared by all stage workers
+func RateLimit(rate float64, burst int) Option {
+	checkRate(rate)
+
+	return func(cfg *stageConfig) {
+		cfg.limiter = cfg.typed("RateLimit", newTokenBucket(rate, burst))
+	}
+}
+
+// same as `RateLimit`, but each key returned by `key` has its own quota
+//
+// if `key` panics, the item is skipped and the panic is passed to the pipeline handler
+func RateLimitByKey[T any, K comparable](rate float64, burst int, key func(T) K) Option {
+	checkRate(rate)
+
+	return func(cfg *stageConfig) {
+		cfg.limiter = cfg.typed("RateLimitByKey", newKeyedLimiter(rate, burst, key))
+	}
+}
+
+// rate limiter applied to the items before processing
+type limiter[T any] interface {
+	// wait a quota for the item, returns `context.Cause(ctx)` on cancellation
+	// and `*PanicError` if item key can't be calculated
+	wait(ctx context.Context, st *stage, v T) error
+}
+
+// limiter of `RateLimit` or `RateLimitByKey` option, nil if it's not set
+func stageLimiter[T any](st *stage) limiter[T] {
+	if b, ok := st.cfg.limiter.v.(*tokenBucket); ok {
+		return bucketLimiter[T]{b}
+	}
+
+	l, _ := typedOption[limiter[T]](st, st.cfg.limiter)
+	return l
+}
+
+// `tokenBucket` shared by items of any key
+type bucketLimiter[T any] struct {
+	*tokenBucket
+}
+
+func (l bucketLimiter[T]) wait(ctx context.Context, _ *stage, _ T) error {
+	return l.sleep(ctx, l.reserve(GetClock(ctx).Now()))
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64 // negative value means that tokens are reserved in advance
+	last   time.Time
+}
+
+func checkRate(rate float64) {
+	if rate <= 0 {
+		panic("rate limit must be positive")
+	}
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	burstF := float64(max(burst, 1))
+	return &tokenBucket{
+		rate:   rate,
+		burst:  burstF,
+		tokens: burstF,
+	}
+}
+
+// wait for the reserved token, it's returned back on cancellation
+func (b *tokenBucket) sleep(ctx context.Context, delay time.Duration) error {
+	if !sleep(ctx, delay) {
+		b.refund()
+		return context.Cause(ctx)
+	}
+	return nil
+}
+
+// take a token and return the delay after which it becomes available
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	b.tokens -= 1
+
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+1, b.burst)
+}
+
+// check whether bucket is full, i.e. it's not used recently
+func (b *tokenBucket) isFull(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	return b.tokens >= b.burst
+}
+
+// must be called with `mu` locked
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.last.IsZero() && now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+type keyedLimiter[T any, K comparable] struct {
+	rate  float64
+	burst int
+	key   func(T) K
+
+	mu        sync.Mutex
+	buckets   map[K]*tokenBucket
+	sweepSize int // sweep idle buckets when map grows to this size
+}
+
+func newKeyedLimiter[T any, K comparable](rate float64, burst int, key func(T) K) *keyedLimiter[T, K] {
+	return &keyedLimiter[T, K]{
+		rate:    rate,
+		burst:   burst,
+		key:     key,
+		buckets: make(map[K]*tokenBucket),
+	}
+}
+
+func (l *keyedLimiter[T, K]) wait(ctx context.Context, st *stage, v T) error {
+	var key K
+	if err := st.safeCall(func() error { key = l.key(v); return nil }); err != nil {
+		return err
+	}
+
+	now := GetClock(ctx).Now()
+
+	// reserve under lock, so the bucket can't be swept meanwhile
+	l.mu.Lock()
+	b := l.bucket(key, now)
+	delay := b.reserve(now)
+	l.mu.Unlock()
+
+	return b.sleep(ctx, delay)
+}
+
+// take a token of `key` and return the delay after which it becomes available
+func (l *keyedLimiter[T, K]) reserve(key K, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.bucket(key, now).reserve(now)
+}
+
+// must be called with `mu` locked
+func (l *keyedLimiter[T, K]) bucket(key K, now time.Time) *tokenBucket {
+	b, ok := l.buckets[key]
+	if ok {
+		return b
+	}
+
+	if len(l.buckets) >= l.sweepSize {
+		// full buckets are equal to the new ones, so they can be dropped
+		for k, b := range l.buckets {
+			if b.isFull(now) {
+				delete(l.buckets, k)
+			}
+		}
+		l.sweepSize = max(2*len(l.buckets), 64)
+	}
+
+	b = newTokenBucket(l.rate, l.burst)
+	l.buckets[key] = b
+	return b
+}
+
+// items of `ThrottleByKey` that wait for their quota, ordered by ready time
+type throttleQueue[T any] struct {
+	items []throttledItem[T]
+}
+
+type throttledItem[T any] struct {
+	it    item[T]
+	ready time.Time
+	seq   uint64 // items that are ready at the same time are passed in the input order
+}
+
+func (q *throttleQueue[T]) Len() int {
+	return len(q.items)
+}
+
+func (q *throttleQueue[T]) Less(i, j int) bool {
+	a, b := q.items[i], q.items[j]
+	if !a.ready.Equal(b.ready) {
+		return a.ready.Before(b.ready)
+	}
+	return a.seq < b.seq
+}
+
+func (q *throttleQueue[T]) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+}
+
+func (q *throttleQueue[T]) Push(x any) {
+	q.items = append(q.items, x.(throttledItem[T]))
+}
+
+func (q *throttleQueue[T]) Pop() any {
+	last := q.items[len(q.items)-1]
+	q.items[len(q.items)-1] = throttledItem[T]{}
+	q.items = q.items[:len(q.items)-1]
+	return last
+}
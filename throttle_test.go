@@ -0,0 +1,183 @@
+package pipeline_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottle(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 15)
+	res := pl.Throttle(ctx, seq, 1000, 5)
+
+	start := time.Now()
+	pipelinetest.WithTimeout(t, "read throttled", func() {
+		index := 0
+		for v := range res {
+			assert.Equal(t, index, v)
+			index += 1
+		}
+		assert.Equal(t, 15, index)
+	})
+
+	// first 5 items are passed immediately, others at 1ms rate
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+}
+
+func TestThrottle_Burst(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 10)
+	res := pl.Throttle(ctx, seq, 0.001, 3)
+
+	for range 3 {
+		pipelinetest.CheckRead(t, res)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	pipelinetest.CheckPending(t, res) // quota is exceeded
+}
+
+func TestThrottle_DontStuck(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	seq := sequence(ctx, 0, 10)
+	res := pl.Throttle(ctx, seq, 0.001, 1)
+	pipelinetest.CheckRead(t, res)
+
+	// stage waits for a token for ~1000s
+	pipelinetest.CheckShutdown(t, cancel)
+}
+
+func TestThrottleByKey(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan string, 10)
+	res := pl.ThrottleByKey(ctx, input, 0.001, 1, func(s string) byte {
+		return s[0]
+	})
+
+	input <- "a1"
+	input <- "b1"
+	assert.Equal(t, "a1", pipelinetest.CheckRead(t, res))
+	assert.Equal(t, "b1", pipelinetest.CheckRead(t, res))
+
+	input <- "a2"
+	time.Sleep(10 * time.Millisecond)
+	pipelinetest.CheckPending(t, res) // `a` quota is exceeded
+
+	// other keys are not blocked by the waiting item
+	input <- "c1"
+	assert.Equal(t, "c1", pipelinetest.CheckRead(t, res))
+}
+
+func TestThrottleByKey_Close(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan int, 10)
+	res := pl.ThrottleByKey(ctx, input, 1000, 1, func(x int) int { return x % 2 })
+	for k := range 6 {
+		input <- k
+	}
+	close(input)
+
+	// waiting items are passed before the output is closed
+	assert.ElementsMatch(t, []int{0, 1, 2, 3, 4, 5}, pipelinetest.ReadAll(t, res))
+}
+
+func TestRateLimit(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 10)
+	res := pl.Transform(ctx, 4, seq, func(x int) int {
+		return x
+	}, pl.RateLimit(0.001, 4))
+
+	for range 4 {
+		pipelinetest.CheckRead(t, res)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	pipelinetest.CheckPending(t, res) // quota is shared by all workers
+}
+
+func TestRateLimitByKey(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	sum := adder{}
+
+	seq := sequence(ctx, 0, 10)
+	_ = pl.Process(ctx, 4, seq, func(x int) {
+		sum.Add(1)
+	}, pl.RateLimitByKey(0.001, 2, func(x int) bool {
+		return x%2 == 0
+	}))
+
+	// 2 odd and 2 even items are processed
+	pipelinetest.WithTimeout(t, "wait processed", func() {
+		for sum.Value() != 4 {
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 4, sum.Value())
+}
+
+func TestRateLimitByKey_TypeMismatch(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	byParity := pl.RateLimitByKey(0.001, 1, func(x int) bool { return x%2 == 0 })
+	assert.Panics(t, func() {
+		_ = pl.Process(ctx, 1, make(chan string), func(string) {}, byParity)
+	})
+
+	// option of the context is ignored by stages of other types
+	strs := make(chan string, 3)
+	strs <- "a"
+	strs <- "b"
+	strs <- "c"
+	close(strs)
+	finished := pl.Process(pl.WithOptions(ctx, byParity), 1, strs, func(string) {})
+	pipelinetest.CheckSignaled(t, finished)
+}
+
+func TestRateLimitByKey_Panic(t *testing.T) {
+	ctx, cancel, p := newPanicPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 4)
+	res := pl.Transform(ctx, 1, seq, func(x int) int { return x }, pl.RateLimitByKey(1000, 4, func(x int) int {
+		if x == 2 {
+			panic("bad key")
+		}
+		return x
+	}))
+
+	// the item is skipped, the worker keeps running
+	assert.Equal(t, []int{0, 1, 3}, pipelinetest.ReadAll(t, res))
+
+	errs := p.Get()
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, 2, errs[0].Item)
+	}
+}
+
+func TestRateLimit_InvalidRate(t *testing.T) {
+	assert.Panics(t, func() {
+		_ = pl.RateLimit(0, 1)
+	})
+}
@@ -0,0 +1,233 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// receives trace events of a pipeline, see `WithTracer`
+//
+// `Trace` is called concurrently from multiple goroutines
+type Tracer interface {
+	Trace(ev TraceEvent)
+}
+
+// unique (per tracer) identifier of a stage, a callback call or an item
+type SpanID uint64
+
+type TraceKind int
+
+const (
+	StageStart TraceKind = iota + 1 // stage was created
+	StageEnd                        // all stage goroutines have exited
+	CallStart                       // stage callback was called
+	CallEnd                         // stage callback has returned, `Err` holds its result
+	ItemStart                       // `Generate` has created a new item
+)
+
+var traceKindNames = [...]string{
+	StageStart: "stage_start",
+	StageEnd:   "stage_end",
+	CallStart:  "call_start",
+	CallEnd:    "call_end",
+	ItemStart:  "item_start",
+}
+
+func (k TraceKind) String() string {
+	if k > 0 && int(k) < len(traceKindNames) {
+		return traceKindNames[k]
+	}
+	return fmt.Sprintf("TraceKind(%d)", int(k))
+}
+
+func (k TraceKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *TraceKind) UnmarshalText(text []byte) error {
+	index := slices.Index(traceKindNames[:], string(text))
+	if index <= 0 {
+		return fmt.Errorf("unknown trace kind %q", text)
+	}
+
+	*k = TraceKind(index)
+	return nil
+}
+
+type TraceEvent struct {
+	Kind TraceKind
+	Time time.Time
+
+	// stage span for `Stage*`, call span for `Call*` and item span for `ItemStart`
+	Span SpanID
+
+	// stage span for `Call*` and `ItemStart` events
+	Parent SpanID
+
+	// item span created by `Generate` and propagated through later stages,
+	// 0 if a call doesn't process an item (e.g. `Collect`)
+	Item SpanID
+
+	Stage string // stage name, see `Name`
+	API   string // pipeline function that created the stage, e.g. "Transform"
+
+	Err error // result of `CallEnd`
+}
+
+// attach tracer to all stages created with returned context
+//
+// item spans are propagated only between pipeline stages, items
+// read by other code (e.g. by `Read`) lose their span
+func WithTracer(ctx context.Context, tracer Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey, &tracing{tracer: tracer})
+}
+
+type tracing struct {
+	tracer Tracer
+	lastID atomic.Uint64
+	links  linkRegistry // used if context was created without `NewPipeline`
+}
+
+func getTracing(ctx context.Context) *tracing {
+	tr, _ := ctx.Value(tracerKey).(*tracing)
+	return tr
+}
+
+func (tr *tracing) newSpan() SpanID {
+	return SpanID(tr.lastID.Add(1))
+}
+
+func (tr *tracing) emit(ev TraceEvent) {
+	tr.tracer.Trace(ev)
+}
+
+// in-memory tracer, mostly useful for tests and debugging
+type TraceRecorder struct {
+	mu     sync.Mutex
+	events []TraceEvent
+}
+
+func (r *TraceRecorder) Trace(ev TraceEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+}
+
+// return all recorded events in the order of arrival
+func (r *TraceRecorder) Events() []TraceEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.events)
+}
+
+// tracer that writes events as JSON lines
+type TraceWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+func NewTraceWriter(w io.Writer) *TraceWriter {
+	return &TraceWriter{enc: json.NewEncoder(w)}
+}
+
+type traceRecord struct {
+	Kind   TraceKind `json:"kind"`
+	Time   time.Time `json:"time"`
+	Span   SpanID    `json:"span"`
+	Parent SpanID    `json:"parent,omitempty"`
+	Item   SpanID    `json:"item,omitempty"`
+	Stage  string    `json:"stage"`
+	API    string    `json:"api"`
+	Error  string    `json:"error,omitempty"`
+}
+
+func (w *TraceWriter) Trace(ev TraceEvent) {
+	rec := traceRecord{
+		Kind:   ev.Kind,
+		Time:   ev.Time,
+		Span:   ev.Span,
+		Parent: ev.Parent,
+		Item:   ev.Item,
+		Stage:  ev.Stage,
+		API:    ev.API,
+	}
+	if ev.Err != nil {
+		rec.Error = ev.Err.Error()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err == nil {
+		w.err = w.enc.Encode(rec)
+	}
+}
+
+// return the first write error, events are dropped after it
+func (w *TraceWriter) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// JSON-lines tracer that writes to a file
+type TraceFile struct {
+	*TraceWriter
+	file *os.File
+}
+
+func CreateTraceFile(path string) (*TraceFile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TraceFile{NewTraceWriter(f), f}, nil
+}
+
+// close the file, must be called after pipeline shutdown
+func (f *TraceFile) Close() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	return f.Err()
+}
+
+// read events written by `TraceWriter`, errors are restored as plain text errors
+func ReadTrace(r io.Reader) ([]TraceEvent, error) {
+	var events []TraceEvent
+
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		var rec traceRecord
+		if err := dec.Decode(&rec); err != nil {
+			return events, err
+		}
+
+		ev := TraceEvent{
+			Kind:   rec.Kind,
+			Time:   rec.Time,
+			Span:   rec.Span,
+			Parent: rec.Parent,
+			Item:   rec.Item,
+			Stage:  rec.Stage,
+			API:    rec.API,
+		}
+		if rec.Error != "" {
+			ev.Err = errors.New(rec.Error)
+		}
+
+		events = append(events, ev)
+	}
+
+	return events, nil
+}
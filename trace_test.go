@@ -0,0 +1,225 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTracedPipeline() (context.Context, context.CancelFunc, *pl.TraceRecorder) {
+	rec := &pl.TraceRecorder{}
+	ctx, cancel := pl.NewPipeline(pl.WithTracer(context.Background(), rec))
+	return ctx, cancel, rec
+}
+
+func eventsOf(events []pl.TraceEvent, kind pl.TraceKind, api string) (res []pl.TraceEvent) {
+	for _, ev := range events {
+		if ev.Kind == kind && ev.API == api {
+			res = append(res, ev)
+		}
+	}
+	return
+}
+
+func TestTrace_Stages(t *testing.T) {
+	ctx, cancel, rec := newTracedPipeline()
+
+	seq := sequence(ctx, 0, 3)
+	finished := pl.Process(pl.WithStageName(ctx, "sink"), 2, seq, func(x int) {})
+
+	pipelinetest.CheckSignaled(t, finished)
+	pipelinetest.CheckShutdown(t, cancel)
+
+	events := rec.Events()
+
+	gen := eventsOf(events, pl.StageStart, "Generate")
+	genEnd := eventsOf(events, pl.StageEnd, "Generate")
+	if assert.Len(t, gen, 1) && assert.Len(t, genEnd, 1) {
+		assert.Equal(t, "Generate#0", gen[0].Stage)
+		assert.Equal(t, gen[0].Span, genEnd[0].Span)
+		assert.False(t, gen[0].Time.IsZero())
+	}
+
+	proc := eventsOf(events, pl.StageStart, "Process")
+	procEnd := eventsOf(events, pl.StageEnd, "Process")
+	if assert.Len(t, proc, 1) && assert.Len(t, procEnd, 1) {
+		assert.Equal(t, "sink", proc[0].Stage)
+		assert.Equal(t, proc[0].Span, procEnd[0].Span)
+	}
+
+	calls := eventsOf(events, pl.CallStart, "Process")
+	callsEnd := eventsOf(events, pl.CallEnd, "Process")
+	assert.Len(t, calls, 3)
+	assert.Len(t, callsEnd, 3)
+	for _, ev := range calls {
+		assert.Equal(t, proc[0].Span, ev.Parent)
+	}
+}
+
+func TestTrace_PropagateItemSpan(t *testing.T) {
+	ctx, cancel, rec := newTracedPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 10)
+	doubled := pl.Transform(ctx, 4, seq, func(x int) int { return x * 2 })
+	ordered := pl.TransformOrdered(ctx, 2, 4, doubled, func(x int) int { return x + 1 })
+	finished := pl.Process(ctx, 3, ordered, func(x int) {})
+
+	pipelinetest.CheckSignaled(t, finished)
+
+	events := rec.Events()
+
+	items := eventsOf(events, pl.ItemStart, "Generate")
+	assert.Len(t, items, 10)
+
+	itemSpans := map[pl.SpanID]int{}
+	for _, ev := range items {
+		itemSpans[ev.Span] = 0
+	}
+
+	for _, api := range []string{"Transform", "TransformOrdered", "Process"} {
+		calls := eventsOf(events, pl.CallEnd, api)
+		assert.Len(t, calls, 10, api)
+
+		for _, ev := range calls {
+			_, ok := itemSpans[ev.Item]
+			assert.True(t, ok, "%s call has unknown item span %d", api, ev.Item)
+			itemSpans[ev.Item] += 1
+		}
+	}
+
+	for span, count := range itemSpans {
+		assert.Equal(t, 3, count, "item %d was not processed by every stage", span)
+	}
+}
+
+func TestTrace_CallError(t *testing.T) {
+	ctx, cancel, rec := newTracedPipeline()
+
+	seq := sequence(ctx, 0, 10)
+	_, cherr := pl.ProcessErr(ctx, 1, seq, func(x int) error {
+		if x == 3 {
+			return errTest
+		}
+		return nil
+	})
+
+	err := pipelinetest.CheckRead(t, cherr)
+	assert.Equal(t, errTest, err)
+
+	pipelinetest.CheckShutdown(t, cancel)
+
+	calls := eventsOf(rec.Events(), pl.CallEnd, "ProcessErr")
+	if assert.Len(t, calls, 4) {
+		assert.Nil(t, calls[0].Err)
+		assert.Equal(t, errTest, calls[3].Err)
+	}
+}
+
+func TestTrace_UntracedConsumer(t *testing.T) {
+	ctx, cancel, rec := newTracedPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 10)
+	res := pl.Collect(ctx, func() (sum int) {
+		for v := range seq {
+			sum += v
+		}
+		return
+	})
+
+	assert.Equal(t, 45, pipelinetest.CheckRead(t, res))
+	assert.Len(t, eventsOf(rec.Events(), pl.CallEnd, "Collect"), 1)
+}
+
+func TestTrace_KeepOrder(t *testing.T) {
+	ctx, cancel, rec := newTracedPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 20)
+	buffered := pl.Transform(pl.WithOptions(ctx, pl.Buffer(16)), 1, seq, func(x int) int { return x })
+
+	// downstream is attached when some items are already buffered
+	pipelinetest.WithTimeout(t, "fill buffer", func() {
+		for len(buffered) != cap(buffered) {
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	res := pl.TransformOrdered(ctx, 1, 1, buffered, func(x int) int { return x })
+
+	expected := make([]int, 20)
+	for k := range expected {
+		expected[k] = k
+	}
+	assert.Equal(t, expected, pipelinetest.ReadAll(t, res))
+
+	// items written after the downstream was attached keep their spans
+	calls := eventsOf(rec.Events(), pl.CallEnd, "TransformOrdered")
+	assert.Len(t, calls, 20)
+	assert.NotZero(t, calls[19].Item)
+}
+
+func TestTraceWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := pl.NewTraceWriter(&buf)
+
+	ctx, cancel := pl.NewPipeline(pl.WithTracer(context.Background(), w))
+
+	seq := sequence(ctx, 0, 2)
+	_, cherr := pl.ProcessErr(ctx, 1, seq, func(x int) error {
+		if x == 1 {
+			return errTest
+		}
+		return nil
+	})
+
+	assert.Equal(t, errTest, pipelinetest.CheckRead(t, cherr))
+	pipelinetest.CheckShutdown(t, cancel)
+
+	assert.NoError(t, w.Err())
+	assert.Contains(t, buf.String(), `"kind":"stage_start"`)
+
+	events, err := pl.ReadTrace(&buf)
+	assert.NoError(t, err)
+
+	calls := eventsOf(events, pl.CallEnd, "ProcessErr")
+	if assert.Len(t, calls, 2) {
+		assert.Nil(t, calls[0].Err)
+		assert.EqualError(t, calls[1].Err, "test")
+		assert.NotZero(t, calls[1].Item)
+	}
+}
+
+func TestTraceFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+
+	f, err := pl.CreateTraceFile(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctx, cancel := pl.NewPipeline(pl.WithTracer(context.Background(), f))
+	res := pl.Collect(ctx, func() int { return 42 })
+	assert.Equal(t, 42, pipelinetest.CheckRead(t, res))
+	pipelinetest.CheckShutdown(t, cancel)
+
+	assert.NoError(t, f.Close())
+
+	data, err := os.Open(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer data.Close()
+
+	events, err := pl.ReadTrace(data)
+	assert.NoError(t, err)
+	assert.Len(t, events, 4) // stage start, call start, call end, stage end
+}
@@ -2,85 +2,91 @@ package pipeline
 
 import (
 	"context"
-	"sync"
 	"sync/atomic"
 )
 
-func Transform[T any, U any](ctx context.Context, threads int, in <-chan T, cb func(T) U) <-chan U {
-	out := make(chan U)
-
-	var wg sync.WaitGroup
-	wg.Add(threads)
-
-	for range threads {
-		Go(ctx, func() {
-			defer wg.Done()
+func Transform[T any, U any](ctx context.Context, threads int, in <-chan T, cb func(T) U, opts ...Option) <-chan U {
+	out, _ := transform(ctx, "Transform", threads, in, false, opts, func(v T) (U, bool, error) {
+		return cb(v), true, nil
+	})
+	return out
+}
 
-			for {
-				v, ok := Read(ctx, in)
-				if !ok {
-					return
-				}
+func TransformErr[T any, U any](ctx context.Context, threads int, in <-chan T, cb func(T) (U, error), opts ...Option) (<-chan U, Oneshot[error]) {
+	return transform(ctx, "TransformErr", threads, in, true, opts, func(v T) (U, bool, error) {
+		r, err := cb(v)
+		return r, true, err
+	})
+}
 
-				if !Write(ctx, out, cb(v)) {
-					return
-				}
-			}
-		})
+// common implementation of stages that emit at most one result per item
+//
+// `cb` returns `emit = false` to drop the item; if stage is not `fallible`,
+// only panics are expected, they are reported to the pipeline handler and the item is skipped
+func transform[T any, U any](ctx context.Context, api string, threads int, in <-chan T, fallible bool, opts []Option, cb func(T) (U, bool, error)) (<-chan U, Oneshot[error]) {
+	st := newStage(ctx, api, opts)
+	input := newInput(st, in)
+	out := newOutput[U](st)
+
+	var cherr OneshotMut[error]
+	if fallible {
+		cherr = NewOneshotGroup[error](threads) // each worker can send one error
+		setupDeadLetters[T](st)
 	}
 
-	closeAfterAll(ctx, &wg, nil, out)
+	hasError := atomic.Bool{}
+	handle := transformItem(ctx, st, fallible, cherr, &hasError, out, cb)
+	workers := startWorkers(ctx, st, threads, input, handle)
 
-	return out
-}
+	closeAfterAll(ctx, st, workers, &hasError, out)
 
-func TransformErr[T any, U any](ctx context.Context, threads int, in <-chan T, cb func(T) (U, error)) (<-chan U, Oneshot[error]) {
-	out := make(chan U)
-	cherr := NewOneshotGroup[error](threads) // each worker can send one error
+	return out.ch, cherr.Chan()
+}
 
-	var wg sync.WaitGroup
-	wg.Add(threads)
+// worker handler of `transform`: call `cb` for the item and emit its result
+//
+// `cherr` is used only by `fallible` stage, `hasError` is set when the stage fails
+func transformItem[T any, U any](ctx context.Context, st *stage, fallible bool, cherr OneshotMut[error], hasError *atomic.Bool, out *output[U], cb func(T) (U, bool, error)) func(item[T]) bool {
+	return func(it item[T]) bool {
+		var r U
+		var emit bool
+		err := st.callRetry(ctx, it.span, func() (err error) {
+			r, emit, err = cb(it.val)
+			return
+		})
+		if err != nil {
+			st.fail()
 
-	hasError := atomic.Bool{}
+			if !fallible {
+				reportItemPanic(ctx, it.val, err)
+				return true // skip failed item
+			}
 
-	for range threads {
-		Go(ctx, func() {
-			defer wg.Done()
-
-			for {
-				v, ok := Read(ctx, in)
-				if !ok {
-					return
-				}
-
-				r, err := cb(v)
-				if err != nil {
-					cherr.Write(err)
-					hasError.Store(true)
-					return
-				}
-
-				if !Write(ctx, out, r) {
-					return
-				}
+			if err = st.deadLetter(ctx, it.val, err); err == nil {
+				return true // failed item is sent to dead letters
 			}
-		})
-	}
 
-	closeAfterAll(ctx, &wg, &hasError, out)
+			st.reportError(ctx, err)
+			hasError.Store(true)
+			cherr.tryWrite(err) // `Pool`, `Autoscale` and keyed stages can fail more times than `cherr` holds
+			return false
+		}
 
-	return out, cherr.Chan()
+		if !emit {
+			return true
+		}
+
+		return out.write(ctx, item[U]{val: r, span: it.span})
+	}
 }
 
-func closeAfterAll[T any](ctx context.Context, wg *sync.WaitGroup, hasError *atomic.Bool, ch chan T) {
-	pipelineWg := getWaitGroup(ctx)
-	pipelineWg.Add(1)
-	go func() {
-		defer pipelineWg.Done()
-		wg.Wait()
+func closeAfterAll[T any](ctx context.Context, st *stage, workers *workerGroup, hasError *atomic.Bool, out *output[T]) {
+	spawn(ctx, st.origin(), func() {
+		defer st.end()
+		workers.wait()
 
 		if hasError == nil || !hasError.Load() {
-			close(ch) // don't close channel on error
+			out.close() // don't close channel on error
 		}
-	}()
+	})
 }
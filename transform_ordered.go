@@ -0,0 +1,184 @@
+package pipeline
+
+import (
+	"context"
+)
+
+// same as `Transform`, but results are emitted in the input order
+//
+// `window` limits the number of items that are processed or wait to be emitted,
+// so a single slow item stalls the stage instead of buffering unbounded results;
+// `Pool`, `Autoscale` and `RateLimit` options work as for `Transform`
+func TransformOrdered[T any, U any](ctx context.Context, threads int, window int, in <-chan T, cb func(T) U, opts ...Option) <-chan U {
+	return transformOrdered(ctx, "TransformOrdered", threads, window, in, func(v T) (U, error) {
+		return cb(v), nil
+	}, nil, opts)
+}
+
+func TransformOrderedErr[T any, U any](ctx context.Context, threads int, window int, in <-chan T, cb func(T) (U, error), opts ...Option) (<-chan U, Oneshot[error]) {
+	cherr := NewOneshotGroup[error](max(threads, 1)) // each worker can send one error
+	out := transformOrdered(ctx, "TransformOrderedErr", threads, window, in, cb, &cherr, opts)
+	return out, cherr.Chan()
+}
+
+type orderedJob[T any, U any] struct {
+	it  item[T]
+	res chan<- orderedResult[U]
+}
+
+type orderedResult[U any] struct {
+	it   item[U]
+	ok   bool // `false` if item processing has failed
+	skip bool // item was dropped due to panic in non-fallible version
+}
+
+// `cherr` is nil for non-fallible version, panics are reported to the pipeline handler then
+func transformOrdered[T any, U any](ctx context.Context, api string, threads int, window int, in <-chan T, cb func(T) (U, error), cherr *OneshotMut[error], opts []Option) <-chan U {
+	if window < 1 {
+		window = 1
+	}
+
+	st := newStage(ctx, api, opts)
+	input := newInput(st, in)
+	out := newOutput[U](st)
+	if cherr != nil {
+		setupDeadLetters[T](st)
+	}
+
+	jobs := make(chan orderedJob[T, U])
+
+	// result slots in the input order, the one that emitter waits for is not counted
+	pending := make(chan chan orderedResult[U], window-1)
+
+	// dispatcher: reserve result slot for each item before passing it to workers
+	spawn(ctx, st.origin(), func() {
+		defer close(jobs)
+		defer close(pending)
+
+		for {
+			it, ok := input.read(ctx)
+			if !ok {
+				return
+			}
+
+			res := make(chan orderedResult[U], 1)
+			if !Write(ctx, pending, res) {
+				return
+			}
+
+			if !Write(ctx, jobs, orderedJob[T, U]{it, res}) {
+				return
+			}
+		}
+	})
+
+	rate := stageLimiter[T](st)
+
+	// handle the job, returns `false` if the stage has failed or is cancelled
+	handle := func(job orderedJob[T, U]) bool {
+		if rate != nil {
+			err := rate.wait(ctx, st, job.it.val)
+			if perr, isPanic := err.(*PanicError); isPanic {
+				// key function panicked, the item is skipped
+				st.fail()
+				reportItemPanic(ctx, job.it.val, perr)
+				job.res <- orderedResult[U]{skip: true}
+				return true
+			}
+			if err != nil {
+				return false
+			}
+		}
+
+		var r U
+		err := st.callRetry(ctx, job.it.span, func() (err error) {
+			r, err = cb(job.it.val)
+			return
+		})
+		if err != nil && cherr == nil {
+			st.fail()
+			reportItemPanic(ctx, job.it.val, err)
+			job.res <- orderedResult[U]{skip: true}
+			return true
+		}
+
+		if err != nil {
+			st.fail()
+			if err = st.deadLetter(ctx, job.it.val, err); err == nil {
+				job.res <- orderedResult[U]{skip: true} // failed item is sent to dead letters
+				return true
+			}
+
+			st.reportError(ctx, err)
+
+			// note: `Pool` and `Autoscale` can run more workers than `cherr` holds
+			cherr.tryWrite(err)
+			job.res <- orderedResult[U]{} // unblock emitter
+			return false
+		}
+
+		job.res <- orderedResult[U]{it: item[U]{val: r, span: job.it.span, wm: job.it.wm}, ok: true}
+		return true
+	}
+
+	var group *workerGroup
+	group = newWorkerGroup(func() {
+		st.stats.workerStarted()
+		spawn(ctx, st.origin(), func() {
+			defer st.stats.workerStopped()
+
+			for {
+				retire, wake := group.retire()
+				if retire {
+					return
+				}
+
+				job, ok, woken := readJob(ctx, group, wake, jobs)
+				if woken {
+					continue // group was downsized, check whether to retire
+				}
+
+				if !ok || !handle(job) {
+					group.exit()
+					return
+				}
+			}
+		})
+	})
+
+	// items wait in the input while all workers are busy
+	st.startGroup(ctx, group, threads, input.backlog)
+
+	// emitter: wait results one by one in the input order
+	spawn(ctx, st.origin(), func() {
+		defer st.end()
+
+		for {
+			res, ok := Read(ctx, pending)
+			if !ok {
+				break
+			}
+
+			r, ok := Read(ctx, res)
+			if !ok {
+				break
+			}
+
+			if r.skip {
+				continue
+			}
+
+			if !r.ok {
+				return // don't close channel on error
+			}
+
+			if !out.write(ctx, r.it) {
+				break
+			}
+		}
+
+		out.close()
+	})
+
+	return out.ch
+}
@@ -0,0 +1,191 @@
+package pipeline_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransformOrdered(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 100)
+	res := pl.TransformOrdered(ctx, 8, 16, seq, func(x int) int {
+		// later items finish first
+		time.Sleep(time.Duration(100-x) * 10 * time.Microsecond)
+		return x * 2
+	})
+
+	pipelinetest.WithTimeout(t, "read ordered vals", func() {
+		index := 0
+		for v := range res {
+			assert.Equal(t, index*2, v)
+			index += 1
+		}
+		assert.Equal(t, 100, index)
+	})
+}
+
+func TestTransformOrdered_WindowStallsStage(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	window := 4
+
+	input := make(chan int)
+	passFirst := pl.NewSignal()
+	processed := atomic.Int32{}
+
+	res := pl.TransformOrdered(ctx, 8, window, input, func(x int) int {
+		if x == 0 {
+			passFirst.Wait()
+		}
+		processed.Add(1)
+		return x
+	})
+
+	pipelinetest.WithTimeout(t, "fill reorder window", func() {
+		// note: one more item is read by dispatcher that waits for a free slot
+		for k := range window + 1 {
+			input <- k
+		}
+	})
+
+	// slow first item blocks the stage
+	select {
+	case input <- window + 1:
+		t.Fatalf("stage accepted more items than window allows")
+	case <-time.After(10 * time.Millisecond):
+		// success
+	}
+
+	pipelinetest.CheckPending(t, res)
+	assert.Equal(t, int32(window-1), processed.Load())
+
+	passFirst.Set()
+
+	pipelinetest.WithTimeout(t, "read ordered vals", func() {
+		go func() {
+			input <- window + 1
+			close(input)
+		}()
+
+		index := 0
+		for v := range res {
+			assert.Equal(t, index, v)
+			index += 1
+		}
+		assert.Equal(t, window+2, index)
+	})
+}
+
+func TestTransformOrdered_DontStuck(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	inf := pl.Generate(ctx, func(w pl.Writer[int]) {
+		k := 0
+		for w.Write(k) {
+			k += 1
+		}
+	})
+
+	// never read
+	_ = pl.TransformOrdered(ctx, 4, 8, inf, func(x int) int {
+		return x
+	})
+
+	pipelinetest.CheckShutdown(t, cancel)
+}
+
+func TestTransformOrderedErr(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	seq := sequence(ctx, 0, 10)
+	res, cherr := pl.TransformOrderedErr(ctx, 4, 4, seq, func(x int) (int, error) {
+		return x + 5, nil
+	})
+
+	pipelinetest.WithTimeout(t, "read ordered vals", func() {
+		index := 0
+		for v := range res {
+			assert.Equal(t, index+5, v)
+			index += 1
+		}
+		assert.Equal(t, 10, index)
+	})
+
+	pipelinetest.CheckPending(t, cherr)
+}
+
+func TestTransformOrderedErr_Propagate(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	seq := sequence(ctx, 0, 10)
+	res, cherr := pl.TransformOrderedErr(ctx, 4, 4, seq, func(x int) (int, error) {
+		if x == 3 {
+			return 0, errTest
+		}
+		return x, nil
+	})
+
+	pipelinetest.WithTimeout(t, "read vals before error", func() {
+		for k := range 3 {
+			v := pipelinetest.CheckRead(t, res)
+			assert.Equal(t, k, v)
+		}
+	})
+
+	err := pipelinetest.CheckRead(t, cherr)
+	assert.Equal(t, errTest, err)
+
+	pipelinetest.CheckPending(t, res) // items after failed one are never emitted
+
+	pipelinetest.CheckShutdown(t, cancel)
+}
+
+func TestTransformOrdered_Pool(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	pool := &pl.WorkerPool{}
+	b := newBlocker()
+	res := pl.TransformOrdered(ctx, 1, 16, sequence(ctx, 0, 20), b.Call, pl.Pool(pool))
+
+	pool.Resize(4)
+	waitActive(t, b, 4)
+	assert.Equal(t, 4, pool.Running())
+
+	b.release.Set()
+
+	expected := make([]int, 20)
+	for k := range expected {
+		expected[k] = k
+	}
+	assert.Equal(t, expected, pipelinetest.ReadAll(t, res))
+
+	pipelinetest.WithTimeout(t, "wait workers", func() {
+		for pool.Running() != 0 {
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
+
+func TestTransformOrdered_RateLimit(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	start := time.Now()
+	res := pl.TransformOrdered(ctx, 4, 4, sequence(ctx, 0, 6), func(x int) int {
+		return x
+	}, pl.RateLimit(100, 1))
+
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5}, pipelinetest.ReadAll(t, res))
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+}
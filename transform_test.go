@@ -3,9 +3,12 @@ package pipeline_test
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,14 +24,14 @@ func sequence(ctx context.Context, start, count int) <-chan int {
 
 func TestTransform(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	seq := sequence(ctx, 0, 10)
 	seqAdd5 := pl.Transform(ctx, 1, seq, func(x int) int {
 		return x + 5
 	})
 
-	withTimeout(t, "read seq vals", func() {
+	pipelinetest.WithTimeout(t, "read seq vals", func() {
 		index := 0
 		for k := range seqAdd5 {
 			assert.Equal(t, k, index+5)
@@ -40,7 +43,7 @@ func TestTransform(t *testing.T) {
 
 func TestTransform_SpawnWorkers(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	numWorkers := 42
 
@@ -58,16 +61,16 @@ func TestTransform_SpawnWorkers(t *testing.T) {
 		return res{x * 2}
 	})
 
-	withTimeout(t, "count spawned workers", func() {
+	pipelinetest.WithTimeout(t, "count spawned workers", func() {
 		for range numWorkers {
 			input <- 42
 		}
 		started.Wait()
 	})
 
-	withTimeout(t, "check processed results", func() {
+	pipelinetest.WithTimeout(t, "check processed results", func() {
 		close(input)
-		checkPending(t, tr) // all workers are locked by processing
+		pipelinetest.CheckPending(t, tr) // all workers are locked by processing
 
 		passResult.Set()
 
@@ -82,14 +85,14 @@ func TestTransform_SpawnWorkers(t *testing.T) {
 
 func TestTransformErr(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	seq := sequence(ctx, 0, 10)
 	seqAdd5, cherr := pl.TransformErr(ctx, 1, seq, func(x int) (int, error) {
 		return x + 5, nil
 	})
 
-	withTimeout(t, "read seq vals", func() {
+	pipelinetest.WithTimeout(t, "read seq vals", func() {
 		index := 0
 		for k := range seqAdd5 {
 			assert.Equal(t, k, index+5)
@@ -98,12 +101,12 @@ func TestTransformErr(t *testing.T) {
 		assert.Equal(t, index, 10)
 	})
 
-	checkPending(t, cherr)
+	pipelinetest.CheckPending(t, cherr)
 }
 
 func TestTransformErr_SpawnWorkers(t *testing.T) {
 	ctx, cancel := pl.NewPipeline(context.Background())
-	defer checkShutdown(t, cancel)
+	defer pipelinetest.CheckShutdown(t, cancel)
 
 	numWorkers := 42
 
@@ -121,16 +124,16 @@ func TestTransformErr_SpawnWorkers(t *testing.T) {
 		return res{x * 2}, nil
 	})
 
-	withTimeout(t, "count spawned workers", func() {
+	pipelinetest.WithTimeout(t, "count spawned workers", func() {
 		for range numWorkers {
 			input <- 42
 		}
 		started.Wait()
 	})
 
-	withTimeout(t, "check processed results", func() {
+	pipelinetest.WithTimeout(t, "check processed results", func() {
 		close(input)
-		checkPending(t, tr) // all workers are locked by processing
+		pipelinetest.CheckPending(t, tr) // all workers are locked by processing
 
 		passResult.Set()
 
@@ -142,7 +145,7 @@ func TestTransformErr_SpawnWorkers(t *testing.T) {
 		assert.Equal(t, count, numWorkers)
 	})
 
-	checkPending(t, cherr)
+	pipelinetest.CheckPending(t, cherr)
 }
 
 func TestTransformErr_Propagate(t *testing.T) {
@@ -158,20 +161,47 @@ func TestTransformErr_Propagate(t *testing.T) {
 		return 0, errTest
 	})
 
-	withTimeout(t, "receive error", func() {
+	pipelinetest.WithTimeout(t, "receive error", func() {
 		for range numWorkers {
 			input <- 42
 		}
 
 		doFail.Set()
 
-		err := checkRead(t, cherr)
+		err := pipelinetest.CheckRead(t, cherr)
 		assert.Equal(t, err, errTest)
 	})
 
-	checkPending(t, tr) // nothing was processed, all failed
+	pipelinetest.CheckPending(t, tr) // nothing was processed, all failed
 
 	// note: multiple errors were emitted simultaneously,
 	// make sure that no goroutine was stuck
-	checkShutdown(t, cancel)
+	pipelinetest.CheckShutdown(t, cancel)
+}
+
+func TestTransformErr_Pool(t *testing.T) {
+	ctx, cancel, p := newPanicPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	pool := &pl.WorkerPool{}
+	pool.Resize(4) // more workers than `threads`
+
+	var active atomic.Int32
+	failed := pl.NewSignal()
+	res, cherr := pl.TransformErr(ctx, 1, sequence(ctx, 0, 4), func(x int) (int, error) {
+		if active.Add(1) == 4 {
+			failed.Set()
+		}
+		failed.Wait() // all workers fail
+		return 0, errTest
+	}, pl.Pool(pool))
+
+	assert.ErrorIs(t, pipelinetest.CheckRead(t, cherr), errTest)
+	pipelinetest.WithTimeout(t, "wait workers", func() {
+		for pool.Running() != 0 {
+			time.Sleep(time.Millisecond)
+		}
+	})
+	pipelinetest.CheckPending(t, res)
+	assert.Empty(t, p.Get())
 }
@@ -0,0 +1,141 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// assign watermarks to items of `in`, so event-time windows can be emitted, see `EventTime`
+//
+// watermark is the latest event time seen minus `maxDelay`, so items that are more than `maxDelay`
+// older than the latest one are late; watermarks are passed with items through the downstream
+// stages (`Transform`, `FanIn`, etc.) of a pipeline created by `NewPipeline`,
+// so watermark advances only when items are emitted;
+// if `timestamp` panics, the item is skipped and the panic is passed to the pipeline handler
+func AssignWatermarks[T any](ctx context.Context, in <-chan T, timestamp func(T) time.Time, maxDelay time.Duration, opts ...Option) <-chan T {
+	st := newStage(ctx, "AssignWatermarks", opts)
+	input := newInput(st, in)
+	st.watermarked = true
+	out := newOutput[T](st)
+
+	spawn(ctx, st.origin(), func() {
+		defer st.end()
+		defer out.close()
+
+		var latest time.Time
+		for {
+			it, ok := input.read(ctx)
+			if !ok {
+				return
+			}
+
+			var ts time.Time
+			err := st.call(it.span, func() error {
+				ts = timestamp(it.val)
+				return nil
+			})
+			if err != nil {
+				// timestamp function panicked, the item is skipped
+				st.fail()
+				reportItemPanic(ctx, it.val, err)
+				continue
+			}
+
+			if ts.After(latest) {
+				latest = ts
+			}
+
+			it.wm = latest.Add(-maxDelay)
+			if !out.write(ctx, it) {
+				return
+			}
+		}
+	})
+
+	return out.ch
+}
+
+// assign items of `TumblingWindow` or `SlidingWindow` to time windows by `timestamp`
+// instead of processing time, windows are emitted when the watermark passes their end,
+// see `AssignWatermarks`; windows that are still open when the input is closed are flushed,
+// if `timestamp` panics, the item is skipped and the panic is passed to the pipeline handler
+func EventTime[T any](timestamp func(T) time.Time) Option {
+	return func(cfg *stageConfig) {
+		cfg.eventTime = cfg.typed("EventTime", timestamp)
+	}
+}
+
+// send late items of `EventTime` windows to `ch` instead of dropping them,
+// item is late if all its windows are already emitted; `ch` is not closed by the stage
+func LateItems[T any](ch chan<- T) Option {
+	return func(cfg *stageConfig) {
+		cfg.late = cfg.typed("LateItems", ch)
+	}
+}
+
+// output watermark of a stage that reorders items (e.g. `Transform` with several workers),
+// it's kept behind watermarks of all items that are still processed
+type watermarkTracker struct {
+	mu       sync.Mutex
+	inFlight map[uint64]time.Time // input watermark at each item that is processed
+	next     uint64               // sequence number of the next item
+	oldest   uint64               // all items before it are done
+	last     time.Time            // input watermark
+	out      time.Time            // output watermark
+}
+
+func newWatermarkTracker() *watermarkTracker {
+	return &watermarkTracker{inFlight: make(map[uint64]time.Time)}
+}
+
+// register item that is read with input watermark `in`, returns its sequence number;
+// items must be registered in the input order, see `input.readItem`,
+// `done` must be called after the item is processed
+func (wm *watermarkTracker) add(in time.Time) uint64 {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	if in.After(wm.last) {
+		wm.last = in
+	}
+
+	seq := wm.next
+	wm.next += 1
+	wm.inFlight[seq] = wm.last
+	return seq
+}
+
+func (wm *watermarkTracker) done(seq uint64) {
+	if wm == nil {
+		return
+	}
+
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	delete(wm.inFlight, seq)
+}
+
+// watermark of the item that is written now: the processed items and
+// the ones that are not read yet are not older than it
+func (wm *watermarkTracker) output() time.Time {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	for wm.oldest < wm.next {
+		if _, ok := wm.inFlight[wm.oldest]; ok {
+			break
+		}
+		wm.oldest += 1
+	}
+
+	w := wm.last
+	if wm.oldest < wm.next {
+		w = wm.inFlight[wm.oldest]
+	}
+
+	if w.After(wm.out) {
+		wm.out = w
+	}
+	return wm.out
+}
@@ -0,0 +1,253 @@
+package pipeline_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+var eventStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// event time in seconds from `eventStart`
+type event int
+
+func eventTime(ev event) time.Time {
+	return eventStart.Add(time.Duration(ev) * time.Second)
+}
+
+func eventWindows(ctx context.Context, in <-chan event, opts ...pl.Option) <-chan pl.Window[[]event] {
+	return pl.TumblingWindow(ctx, in, pl.TimeWindow(time.Minute), pl.Aggregator[event, []event]{
+		Add: func(acc []event, ev event) []event { return append(acc, ev) },
+	}, append(opts, pl.EventTime(eventTime))...)
+}
+
+func TestEventTimeWindow(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan event)
+	events := pl.AssignWatermarks(ctx, input, eventTime, 10*time.Second)
+	res := eventWindows(ctx, pl.Transform(ctx, 1, events, func(ev event) event { return ev }))
+
+	input <- 0
+	input <- 50
+	input <- 65 // watermark is 55s
+	pipelinetest.CheckPending(t, res)
+
+	input <- 40 // out of order, but not late
+	input <- 70 // watermark passes the end of the first window
+
+	w := pipelinetest.CheckRead(t, res)
+	assert.Equal(t, eventStart, w.Start)
+	assert.Equal(t, eventStart.Add(time.Minute), w.End)
+	assert.ElementsMatch(t, []event{0, 50, 40}, w.Result)
+
+	close(input)
+
+	// open window is flushed
+	w = pipelinetest.CheckRead(t, res)
+	assert.Equal(t, eventStart.Add(time.Minute), w.Start)
+	assert.ElementsMatch(t, []event{65, 70}, w.Result)
+	pipelinetest.ReadAll(t, res) // closed
+}
+
+func TestEventTimeWindow_LateItems(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan event)
+	late := make(chan event, 1)
+	events := pl.AssignWatermarks(ctx, input, eventTime, 0)
+	res := eventWindows(ctx, events, pl.LateItems[event](late))
+
+	input <- 10
+	input <- 70
+	assert.Equal(t, []event{10}, pipelinetest.CheckRead(t, res).Result)
+
+	input <- 20 // its window is already emitted
+	assert.Equal(t, event(20), pipelinetest.CheckRead(t, late))
+
+	close(input)
+	assert.Equal(t, []event{70}, pipelinetest.CheckRead(t, res).Result)
+}
+
+func TestEventTimeWindow_TransformReorder(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	release := make(chan struct{})
+	input := make(chan event)
+	late := make(chan event, 1)
+
+	events := pl.AssignWatermarks(ctx, input, eventTime, 0)
+	slow := pl.Transform(ctx, 2, events, func(ev event) event {
+		if ev == 0 {
+			<-release
+		}
+		return ev
+	})
+	res := eventWindows(ctx, slow, pl.LateItems[event](late))
+
+	input <- 0
+	input <- 70
+
+	// the second item overtakes the first one, but its watermark waits for it
+	pipelinetest.WithTimeout(t, "wait for the second item", func() {
+		for pl.Stats(ctx)[1].Written == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	})
+	pipelinetest.CheckPending(t, res)
+
+	close(release)
+	close(input)
+
+	assert.Equal(t, []event{0}, pipelinetest.CheckRead(t, res).Result)
+	assert.Equal(t, []event{70}, pipelinetest.CheckRead(t, res).Result)
+	pipelinetest.CheckPending(t, late)
+}
+
+func TestFanIn_Watermark(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input1 := make(chan event)
+	input2 := make(chan event)
+	merged, _ := pl.FanIn(ctx,
+		pl.AssignWatermarks(ctx, input1, eventTime, 0),
+		pl.AssignWatermarks(ctx, input2, eventTime, 0),
+	)
+	res := eventWindows(ctx, merged)
+
+	input1 <- 10
+	input2 <- 20
+	input1 <- 130 // the second input holds back the watermark
+	pipelinetest.CheckPending(t, res)
+
+	input2 <- 90 // watermark is 90s
+	assert.ElementsMatch(t, []event{10, 20}, pipelinetest.CheckRead(t, res).Result)
+
+	// closed input doesn't hold back the watermark,
+	// but `FanIn` sees it closed asynchronously
+	close(input2)
+	pipelinetest.WithTimeout(t, "wait for the window", func() {
+		for ev := event(140); ; ev++ {
+			input1 <- ev
+
+			select {
+			case w := <-res:
+				assert.Equal(t, []event{90}, w.Result)
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	})
+
+	close(input1)
+	assert.Equal(t, event(130), pipelinetest.CheckRead(t, res).Result[0])
+	pipelinetest.ReadAll(t, res) // closed
+}
+
+func TestEventTimeWindow_Batch(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan event)
+	events := pl.AssignWatermarks(ctx, input, eventTime, 0)
+	batches := pl.Batch(ctx, events, 2, 0)
+	res := eventWindows(ctx, pl.Transform(ctx, 1, batches, func(b []event) event { return b[0] }))
+
+	input <- 0
+	input <- 65
+	input <- 70
+	input <- 75 // watermark of the batch (of its first item) passes the end of the first window
+
+	assert.Equal(t, []event{0}, pipelinetest.CheckRead(t, res).Result)
+
+	close(input)
+	assert.Equal(t, []event{70}, pipelinetest.CheckRead(t, res).Result)
+}
+
+func TestEventTime_Context(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	// context options are ignored by stages of other types
+	ctx = pl.WithOptions(ctx, pl.EventTime(eventTime), pl.LateItems(make(chan event)))
+
+	input := make(chan int)
+	res := pl.TumblingWindow(ctx, input, pl.CountWindow(2), pl.Aggregator[int, int]{
+		Add: func(acc int, x int) int { return acc + x },
+	})
+
+	input <- 1
+	input <- 2
+	assert.Equal(t, 3, pipelinetest.CheckRead(t, res).Result)
+
+	close(input)
+	pipelinetest.ReadAll(t, res)
+}
+
+func TestEventTime_Invalid(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	assert.Panics(t, func() {
+		pl.TumblingWindow(ctx, make(chan event), pl.TimeWindow(time.Minute), pl.Aggregator[event, int]{
+			Add: func(acc int, ev event) int { return acc + 1 },
+		}, pl.EventTime(func(string) time.Time { return time.Time{} }))
+	})
+	assert.Panics(t, func() {
+		pl.TumblingWindow(ctx, make(chan event), pl.CountWindow(2), pl.Aggregator[event, int]{
+			Add: func(acc int, ev event) int { return acc + 1 },
+		}, pl.EventTime(eventTime))
+	})
+	assert.Panics(t, func() {
+		eventWindows(ctx, make(chan event), pl.LateItems(make(chan int)))
+	})
+}
+
+func TestWatermark_TimestampPanic(t *testing.T) {
+	ctx, cancel, p := newPanicPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	badTime := func(ev event) time.Time {
+		if ev == 20 || ev == 30 {
+			panic("bad event")
+		}
+		return eventTime(ev)
+	}
+
+	input := make(chan event, 5)
+	for _, ev := range []event{10, 20, 30, 40, 70} {
+		input <- ev
+	}
+	close(input)
+
+	events := pl.AssignWatermarks(ctx, input, func(ev event) time.Time {
+		if ev == 20 {
+			panic("bad event")
+		}
+		return eventTime(ev)
+	}, 0)
+	res := pl.TumblingWindow(ctx, events, pl.TimeWindow(time.Minute), pl.Aggregator[event, []event]{
+		Add: func(acc []event, ev event) []event { return append(acc, ev) },
+	}, pl.EventTime(badTime))
+
+	// items are skipped, the stream is not truncated
+	windows := pipelinetest.ReadAll(t, res)
+	if assert.Len(t, windows, 2) {
+		assert.Equal(t, []event{10, 40}, windows[0].Result)
+		assert.Equal(t, []event{70}, windows[1].Result)
+	}
+
+	var items []any
+	for _, perr := range p.Get() {
+		items = append(items, perr.Item)
+	}
+	assert.ElementsMatch(t, []any{event(20), event(30)}, items)
+}
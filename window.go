@@ -0,0 +1,341 @@
+package pipeline
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// size or slide of a window, see `CountWindow` and `TimeWindow`
+type WindowSize struct {
+	count int
+	dur   time.Duration
+}
+
+// window of `n` items
+func CountWindow(n int) WindowSize {
+	if n <= 0 {
+		panic("window size must be positive")
+	}
+	return WindowSize{count: n}
+}
+
+// window of processing time `d`, windows are aligned to multiples of `d`,
+// e.g. `TimeWindow(time.Minute)` windows start at the beginning of each minute
+func TimeWindow(d time.Duration) WindowSize {
+	if d <= 0 {
+		panic("window size must be positive")
+	}
+	return WindowSize{dur: d}
+}
+
+// incremental window aggregation: `Add` is called for each item of the window
+type Aggregator[T any, A any] struct {
+	Init func() A // initial value of a new window, zero value if not set
+	Add  func(acc A, v T) A
+}
+
+func (agg *Aggregator[T, A]) init() (acc A) {
+	if agg.Init != nil {
+		acc = agg.Init()
+	}
+	return
+}
+
+// aggregation result of a window
+type Window[A any] struct {
+	// window bounds `[Start, End)` for time windows,
+	// processing times of the first and the last item for count windows
+	Start time.Time
+	End   time.Time
+
+	Items  int
+	Result A
+}
+
+// split `in` into adjacent non-overlapping windows of `size` and emit their aggregates,
+// empty windows are not emitted; time windows use processing time unless `EventTime` is set
+//
+// the window that is still open when `in` is closed is flushed as is;
+// if `agg` panics, the item is skipped and the panic is passed to the pipeline handler
+func TumblingWindow[T any, A any](ctx context.Context, in <-chan T, size WindowSize, agg Aggregator[T, A], opts ...Option) <-chan Window[A] {
+	return window(ctx, "TumblingWindow", in, size, size, agg, opts)
+}
+
+// emit aggregates of windows of `size` that start every `slide`, so an item can belong
+// to several windows; `size` and `slide` must be both counts or both durations
+//
+// windows that are still open when `in` is closed are flushed as is, items between count windows
+// are dropped if `slide` is greater than `size`; if `agg` panics, the item is skipped
+// and the panic is passed to the pipeline handler
+func SlidingWindow[T any, A any](ctx context.Context, in <-chan T, size WindowSize, slide WindowSize, agg Aggregator[T, A], opts ...Option) <-chan Window[A] {
+	return window(ctx, "SlidingWindow", in, size, slide, agg, opts)
+}
+
+func window[T any, A any](ctx context.Context, api string, in <-chan T, size WindowSize, slide WindowSize, agg Aggregator[T, A], opts []Option) <-chan Window[A] {
+	if size == (WindowSize{}) || slide == (WindowSize{}) {
+		panic("window size is not set")
+	}
+	if (size.count > 0) != (slide.count > 0) {
+		panic("window size and slide must be both counts or both durations")
+	}
+
+	st := newStage(ctx, api, opts)
+
+	timestamp, _ := typedOption[func(T) time.Time](st, st.cfg.eventTime) // nil for processing time
+	if timestamp != nil && size.count > 0 {
+		if !st.cfg.eventTime.fromContext {
+			panic("event time requires time windows")
+		}
+		timestamp = nil
+	}
+
+	late, _ := typedOption[chan<- T](st, st.cfg.late) // nil if late items are dropped
+
+	input := newInput(st, in)
+	out := newOutput[Window[A]](st)
+
+	spawn(ctx, st.origin(), func() {
+		defer st.end()
+		defer out.close()
+
+		w := windows[T, A]{size: size, slide: slide, agg: agg}
+		var wm time.Time // input watermark for event time
+
+		var timer Timer // triggered when the oldest processing time window ends
+		var timerEnd time.Time
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+
+		// call `agg` (and event `timestamp`) for the item, its panic is reported and the item is skipped
+		aggregate := func(it item[T], cb func()) {
+			err := st.call(it.span, func() error {
+				cb()
+				return nil
+			})
+			if err != nil {
+				st.fail()
+				reportItemPanic(ctx, it.val, err)
+			}
+		}
+
+		emit := func(res []Window[A]) bool {
+			for _, r := range res {
+				if !out.write(ctx, item[Window[A]]{val: r, wm: wm}) {
+					return false
+				}
+			}
+			return true
+		}
+
+		for {
+			var expired <-chan time.Time
+			if timer != nil {
+				expired = timer.C()
+			}
+
+			it, ok, timeout := input.readUntil(ctx, expired)
+			if timeout {
+				timer = nil
+			} else if !ok {
+				if ctx.Err() == nil {
+					// input was closed, flush the rest
+					_ = emit(w.flush())
+				}
+				return
+			}
+
+			if timestamp != nil {
+				if it.wm.After(wm) {
+					wm = it.wm
+				}
+
+				if !emit(w.expire(wm)) {
+					return
+				}
+
+				accepted := true
+				aggregate(it, func() { accepted = w.addTime(it.val, timestamp(it.val), wm) })
+				if !accepted && late != nil {
+					if !Write(ctx, late, it.val) {
+						return
+					}
+				}
+				continue
+			}
+
+			now := st.clock.Now()
+			if !emit(w.expire(now)) {
+				return
+			}
+
+			if ok {
+				if size.count > 0 {
+					var full []Window[A]
+					aggregate(it, func() { full = w.addCount(it.val, now) })
+					if !emit(full) {
+						return
+					}
+				} else {
+					aggregate(it, func() { w.addTime(it.val, now, now) })
+				}
+			}
+
+			end, hasEnd := w.nextEnd()
+			if timer != nil && (!hasEnd || !end.Equal(timerEnd)) {
+				timer.Stop()
+				timer = nil
+			}
+			if timer == nil && hasEnd {
+				timer = st.clock.NewTimer(end.Sub(now))
+				timerEnd = end
+			}
+		}
+	})
+
+	return out.ch
+}
+
+// open windows of `window` stage
+type windows[T any, A any] struct {
+	size  WindowSize
+	slide WindowSize
+	agg   Aggregator[T, A]
+
+	open  []*Window[A] // windows that are not emitted yet, ordered by start
+	items int          // count windows: number of items read
+}
+
+// add item to count windows, returns the window that is full
+//
+// windows are not changed if `agg` panics, so the item can be skipped
+func (w *windows[T, A]) addCount(v T, now time.Time) []Window[A] {
+	var win *Window[A] // new window that starts at the item
+	if w.items%w.slide.count == 0 {
+		win = &Window[A]{Start: now, Result: w.agg.init()}
+	}
+
+	results := make([]A, len(w.open))
+	for k, open := range w.open {
+		results[k] = w.agg.Add(open.Result, v)
+	}
+	if win != nil {
+		win.Result = w.agg.Add(win.Result, v)
+		win.Items, win.End = 1, now
+	}
+
+	w.items += 1
+	for k, open := range w.open {
+		open.Items += 1
+		open.Result = results[k]
+		open.End = now
+	}
+	if win != nil {
+		w.open = append(w.open, win)
+	}
+
+	// windows start at different items, so only the oldest one can be full;
+	// there are no windows between them if `slide` is greater than `size`
+	if len(w.open) > 0 && w.open[0].Items == w.size.count {
+		return w.pop(1)
+	}
+	return nil
+}
+
+// add item to time windows that contain `ts`, windows that end by `horizon` are already emitted;
+// returns `false` if item is late, i.e. all its windows are emitted
+//
+// windows are not changed if `agg` panics, so the item can be skipped
+func (w *windows[T, A]) addTime(v T, ts time.Time, horizon time.Time) bool {
+	type update struct {
+		start  time.Time
+		result A
+	}
+
+	var updates []update
+	late := false
+	for start := ts.Truncate(w.slide.dur); start.Add(w.size.dur).After(ts); start = start.Add(-w.slide.dur) {
+		if !start.Add(w.size.dur).After(horizon) {
+			late = true
+			break // this and earlier windows are emitted
+		}
+
+		var acc A
+		if idx, found := w.find(start); found {
+			acc = w.open[idx].Result
+		} else {
+			acc = w.agg.init()
+		}
+		updates = append(updates, update{start, w.agg.Add(acc, v)})
+	}
+
+	for _, u := range updates {
+		win := w.window(u.start)
+		win.Items += 1
+		win.Result = u.result
+	}
+
+	// note: item is dropped if it's between windows, i.e. `slide` is greater than `size`
+	return len(updates) > 0 || !late
+}
+
+// index of the open time window that starts at `start`, or where to insert it
+func (w *windows[T, A]) find(start time.Time) (int, bool) {
+	return slices.BinarySearchFunc(w.open, start, func(win *Window[A], start time.Time) int {
+		return win.Start.Compare(start)
+	})
+}
+
+// open time window that starts at `start`, if it's not opened yet
+func (w *windows[T, A]) window(start time.Time) *Window[A] {
+	idx, found := w.find(start)
+	if found {
+		return w.open[idx]
+	}
+
+	win := &Window[A]{
+		Start: start,
+		End:   start.Add(w.size.dur),
+	}
+	w.open = slices.Insert(w.open, idx, win)
+	return win
+}
+
+// remove time windows that end by `horizon`
+func (w *windows[T, A]) expire(horizon time.Time) []Window[A] {
+	if w.size.dur == 0 {
+		return nil
+	}
+
+	n := 0
+	for n < len(w.open) && !w.open[n].End.After(horizon) {
+		n += 1
+	}
+	return w.pop(n)
+}
+
+// end of the oldest time window
+func (w *windows[T, A]) nextEnd() (time.Time, bool) {
+	if w.size.dur == 0 || len(w.open) == 0 {
+		return time.Time{}, false
+	}
+	return w.open[0].End, true
+}
+
+func (w *windows[T, A]) flush() []Window[A] {
+	return w.pop(len(w.open))
+}
+
+// remove `n` oldest windows, windows are opened by items, so they are never empty
+func (w *windows[T, A]) pop(n int) []Window[A] {
+	var res []Window[A]
+	for _, win := range w.open[:n] {
+		res = append(res, *win)
+	}
+
+	w.open = slices.Delete(w.open, 0, n)
+	return res
+}
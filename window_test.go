@@ -0,0 +1,188 @@
+package pipeline_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pl "github.com/greendwin/pipeline"
+	"github.com/greendwin/pipeline/pipelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+var sumAggregator = pl.Aggregator[int, int]{
+	Add: func(acc int, v int) int {
+		return acc + v
+	},
+}
+
+// results and sizes of windows
+func windowResults(windows []pl.Window[int]) (res [][2]int) {
+	for _, w := range windows {
+		res = append(res, [2]int{w.Result, w.Items})
+	}
+	return
+}
+
+func TestTumblingWindow_Count(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	res := pl.TumblingWindow(ctx, sequence(ctx, 0, 10), pl.CountWindow(4), sumAggregator)
+
+	// the last window is flushed on close
+	assert.Equal(t, [][2]int{{6, 4}, {22, 4}, {17, 2}}, windowResults(pipelinetest.ReadAll(t, res)))
+}
+
+func TestSlidingWindow_Count(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	res := pl.SlidingWindow(ctx, sequence(ctx, 0, 6), pl.CountWindow(3), pl.CountWindow(2), sumAggregator)
+
+	// windows [0 1 2], [2 3 4] and flushed [4 5]
+	assert.Equal(t, [][2]int{{3, 3}, {9, 3}, {9, 2}}, windowResults(pipelinetest.ReadAll(t, res)))
+}
+
+func TestSlidingWindow_CountGaps(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	res := pl.SlidingWindow(ctx, sequence(ctx, 0, 10), pl.CountWindow(2), pl.CountWindow(3), sumAggregator)
+
+	// windows [0 1], [3 4], [6 7] and flushed [9], items between them are dropped
+	assert.Equal(t, [][2]int{{1, 2}, {7, 2}, {13, 2}, {9, 1}}, windowResults(pipelinetest.ReadAll(t, res)))
+}
+
+func TestWindow_Panic(t *testing.T) {
+	ctx, cancel, p := newPanicPipeline()
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	res := pl.SlidingWindow(ctx, sequence(ctx, 0, 10), pl.CountWindow(3), pl.CountWindow(3), pl.Aggregator[int, int]{
+		Add: func(acc int, v int) int {
+			if v == 3 {
+				panic("bad item")
+			}
+			return acc + v
+		},
+	})
+
+	// the item is skipped, windows [0 1 2], [4 5 6] and [7 8 9]
+	assert.Equal(t, [][2]int{{3, 3}, {15, 3}, {24, 3}}, windowResults(pipelinetest.ReadAll(t, res)))
+
+	errs := p.Get()
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, 3, errs[0].Item)
+	}
+}
+
+func TestWindow_Init(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	res := pl.TumblingWindow(ctx, sequence(ctx, 1, 4), pl.CountWindow(2), pl.Aggregator[int, []int]{
+		Init: func() []int { return []int{0} },
+		Add:  func(acc []int, v int) []int { return append(acc, v) },
+	})
+
+	assert.Equal(t, []int{0, 1, 2}, pipelinetest.CheckRead(t, res).Result)
+	assert.Equal(t, []int{0, 3, 4}, pipelinetest.CheckRead(t, res).Result)
+}
+
+func TestWindow_InvalidSize(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	assert.Panics(t, func() { pl.CountWindow(0) })
+	assert.Panics(t, func() { pl.TimeWindow(0) })
+	assert.Panics(t, func() {
+		pl.SlidingWindow(ctx, make(chan int), pl.CountWindow(2), pl.TimeWindow(time.Second), sumAggregator)
+	})
+}
+
+// note: `0` doesn't change sums, it's written after an item to make sure
+// that the item is read before the clock is advanced
+func TestTumblingWindow_Time(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := pipelinetest.NewFakeClock(start)
+	ctx, cancel := pl.NewPipeline(pl.WithClock(context.Background(), clock))
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan int)
+	res := pl.TumblingWindow(ctx, input, pl.TimeWindow(time.Minute), sumAggregator)
+
+	input <- 1
+	input <- 2
+	input <- 0
+
+	clock.Advance(59 * time.Second)
+	pipelinetest.CheckPending(t, res)
+
+	clock.Advance(time.Second)
+	w := pipelinetest.CheckRead(t, res)
+	assert.Equal(t, 3, w.Result)
+	assert.Equal(t, start, w.Start)
+	assert.Equal(t, start.Add(time.Minute), w.End)
+
+	input <- 3
+	close(input)
+
+	// open window is flushed
+	w = pipelinetest.CheckRead(t, res)
+	assert.Equal(t, 3, w.Result)
+	assert.Equal(t, start.Add(time.Minute), w.Start)
+
+	pipelinetest.ReadAll(t, res) // closed
+}
+
+func TestSlidingWindow_Time(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := pipelinetest.NewFakeClock(start)
+	ctx, cancel := pl.NewPipeline(pl.WithClock(context.Background(), clock))
+	defer pipelinetest.CheckShutdown(t, cancel)
+
+	input := make(chan int)
+	res := pl.SlidingWindow(ctx, input, pl.TimeWindow(time.Minute), pl.TimeWindow(30*time.Second), sumAggregator)
+
+	type result struct {
+		start time.Duration
+		sum   int
+	}
+	read := func() result {
+		w := pipelinetest.CheckRead(t, res)
+		assert.Equal(t, w.Start.Add(time.Minute), w.End)
+		return result{w.Start.Sub(start), w.Result}
+	}
+
+	input <- 1 // in windows [-30s, 30s) and [0s, 60s)
+	input <- 0
+
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, result{-30 * time.Second, 1}, read())
+
+	input <- 2 // in windows [0s, 60s) and [30s, 90s)
+	input <- 0
+
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, result{0, 3}, read())
+
+	input <- 3 // in windows [30s, 90s) and [60s, 120s)
+	close(input)
+
+	assert.Equal(t, result{30 * time.Second, 5}, read())
+	assert.Equal(t, result{60 * time.Second, 3}, read())
+	pipelinetest.ReadAll(t, res) // closed
+}
+
+func TestWindow_Cancel(t *testing.T) {
+	ctx, cancel := pl.NewPipeline(context.Background())
+
+	input := make(chan int)
+	res := pl.TumblingWindow(ctx, input, pl.TimeWindow(time.Hour), sumAggregator)
+	input <- 1
+
+	pipelinetest.CheckShutdown(t, cancel)
+
+	// open window is not flushed on cancel
+	assert.Empty(t, pipelinetest.ReadAll(t, res))
+}